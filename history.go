@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/tscolari/bender/history"
+	"github.com/tscolari/bender/metrics"
+	"github.com/urfave/cli"
+)
+
+func historyCommand() cli.Command {
+	return cli.Command{
+		Name:  "history",
+		Usage: "query the runs saved with --save",
+		Subcommands: []cli.Command{
+			{
+				Name:  "list",
+				Usage: "list the saved runs",
+				Flags: []cli.Flag{
+					historyFlag(),
+					cli.StringSliceFlag{
+						Name:  "tag",
+						Usage: "only list the runs with this `TAG`, as key=value. May be set more than once",
+					},
+					cli.StringFlag{
+						Name:  "format",
+						Value: "table",
+						Usage: "output format: json or table",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					format := c.String("format")
+					if format != "json" && format != "table" {
+						return fmt.Errorf("invalid format `%s`: use `json` or `table`", format)
+					}
+
+					tags, err := parseTags(c.StringSlice("tag"))
+					if err != nil {
+						return err
+					}
+
+					entries, err := history.NewStore(historyPath(c)).List(tags)
+					if err != nil {
+						return fmt.Errorf("Failed to read the history: %s", err.Error())
+					}
+
+					if format == "table" {
+						return history.WriteEntries(os.Stdout, entries)
+					}
+
+					// Each run is left out of the summaries, use `show` for the full summary.
+					for i := range entries {
+						entries[i].Summary.EachRun = nil
+					}
+					return json.NewEncoder(os.Stdout).Encode(&entries)
+				},
+			},
+			{
+				Name:      "show",
+				Usage:     "print the summary of a saved run",
+				ArgsUsage: "ID",
+				Flags:     []cli.Flag{historyFlag()},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return errors.New("Missing the run ID argument")
+					}
+
+					id, err := strconv.Atoi(c.Args().First())
+					if err != nil {
+						return fmt.Errorf("invalid run ID `%s`", c.Args().First())
+					}
+
+					entry, err := history.NewStore(historyPath(c)).Get(id)
+					if err != nil {
+						return fmt.Errorf("Failed to read the history: %s", err.Error())
+					}
+
+					return json.NewEncoder(os.Stdout).Encode(&entry.Summary)
+				},
+			},
+			{
+				Name:  "trend",
+				Usage: "show how the median and p95 of a command evolved over the saved runs",
+				Flags: []cli.Flag{
+					historyFlag(),
+					cli.StringFlag{
+						Name:  "command",
+						Usage: "the `COMMAND` line to follow, as given when running the benchmark",
+					},
+					cli.StringSliceFlag{
+						Name:  "tag",
+						Usage: "only use the runs with this `TAG`, as key=value. May be set more than once",
+					},
+					cli.StringFlag{
+						Name:  "format",
+						Value: "table",
+						Usage: "output format: json or table",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.String("command") == "" {
+						return errors.New("Missing the `--command` argument")
+					}
+
+					format := c.String("format")
+					if format != "json" && format != "table" {
+						return fmt.Errorf("invalid format `%s`: use `json` or `table`", format)
+					}
+
+					tags, err := parseTags(c.StringSlice("tag"))
+					if err != nil {
+						return err
+					}
+
+					points, err := history.NewStore(historyPath(c)).Trend(c.String("command"), tags)
+					if err != nil {
+						return fmt.Errorf("Failed to read the history: %s", err.Error())
+					}
+
+					if format == "table" {
+						return history.WriteTrend(os.Stdout, points)
+					}
+					return json.NewEncoder(os.Stdout).Encode(&points)
+				},
+			},
+		},
+	}
+}
+
+func historyFlag() cli.Flag {
+	return cli.StringFlag{
+		Name:   "history",
+		EnvVar: "BENDER_HISTORY",
+		Usage:  "`FILE` of the history of runs (default: ~/.bender/history.jsonl)",
+	}
+}
+
+func historyPath(c *cli.Context) string {
+	if c.String("history") != "" {
+		return c.String("history")
+	}
+	return history.DefaultPath()
+}
+
+// parseTags parses and merges the tags, each in the `key=value` format.
+func parseTags(values []string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, value := range values {
+		parsed, err := metrics.ParseTags(value)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, value := range parsed {
+			tags[key] = value
+		}
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,261 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tscolari/bender/runner"
+)
+
+// Defines a run saved in the history
+// - ID identifies the run in the history, starting at 1
+// - SavedAt is when the run was saved
+// - Tags are the key=value labels given when saving, e.g. branch=main
+// - Summary is the full summary of the run
+type Entry struct {
+	ID      int               `json:"id"`
+	SavedAt time.Time         `json:"saved_at"`
+	Tags    map[string]string `json:"tags,omitempty"`
+	Summary runner.Summary    `json:"summary"`
+}
+
+// Defines how a command performed in one of the runs of the history
+// - ID is the ID of the run
+// - SavedAt is when the run was saved
+// - Tags are the tags of the run
+// - RunCount is how many times the command ran
+// - Median and P95 are the statistics of the command's durations
+type Point struct {
+	ID       int               `json:"id"`
+	SavedAt  time.Time         `json:"saved_at"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	RunCount int               `json:"run_count"`
+	Median   time.Duration     `json:"median"`
+	P95      time.Duration     `json:"p95"`
+}
+
+// Store is the history of runs, kept in a file with one JSON entry per line.
+// Entries are only ever appended to the file.
+type Store struct {
+	path string
+}
+
+// DefaultPath returns the path of the history in the user's home directory.
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, ".bender", "history.jsonl")
+}
+
+// Creates a new instance of the Store, kept in the file at path.
+// The file (and its directory) is only created when the first run is saved.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Save appends the summary to the history, with the next ID. The file is locked
+// while the ID is picked and the entry written, so concurrent runs can save to
+// the same history.
+func (s *Store) Save(summary runner.Summary, tags map[string]string) (Entry, error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return Entry{}, err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer file.Close()
+
+	if err := lockFile(file); err != nil {
+		return Entry{}, err
+	}
+
+	entries, err := s.read()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	entry := Entry{
+		ID:      1,
+		SavedAt: time.Now(),
+		Tags:    tags,
+		Summary: summary,
+	}
+	if len(entries) > 0 {
+		entry.ID = entries[len(entries)-1].ID + 1
+	}
+
+	line, err := json.Marshal(&entry)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	// The entry is written at once, so it's never interleaved with another.
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return Entry{}, err
+	}
+
+	return entry, file.Close()
+}
+
+// List returns the runs that have all the given tags, oldest first.
+func (s *Store) List(tags map[string]string) ([]Entry, error) {
+	entries, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	matching := []Entry{}
+	for _, entry := range entries {
+		if hasTags(entry, tags) {
+			matching = append(matching, entry)
+		}
+	}
+
+	return matching, nil
+}
+
+// Get returns the run with the given ID.
+func (s *Store) Get(id int) (Entry, error) {
+	entries, err := s.read()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+
+	return Entry{}, fmt.Errorf("run %d not found in the history", id)
+}
+
+// Trend returns how the command performed in each of the runs that have all the
+// given tags, oldest first and then in the order of the commands of each run.
+// Runs where the command didn't run, or that have no statistics, are left out.
+func (s *Store) Trend(command string, tags map[string]string) ([]Point, error) {
+	entries, err := s.List(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	points := []Point{}
+	for _, entry := range entries {
+		for i := 1; i <= len(entry.Summary.Commands); i++ {
+			cmd := entry.Summary.Commands[i]
+			if cmd.Exec != command || cmd.Statistics == nil {
+				continue
+			}
+
+			points = append(points, Point{
+				ID:       entry.ID,
+				SavedAt:  entry.SavedAt,
+				Tags:     entry.Tags,
+				RunCount: cmd.RunCount,
+				Median:   cmd.Statistics.Median,
+				P95:      cmd.Statistics.P95,
+			})
+		}
+	}
+
+	return points, nil
+}
+
+// read returns all the entries of the history. A missing file is an empty history.
+func (s *Store) read() ([]Entry, error) {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []Entry{}
+	decoder := json.NewDecoder(file)
+	for {
+		var entry Entry
+		err := decoder.Decode(&entry)
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("corrupted history `%s`: %s", s.path, err.Error())
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func hasTags(entry Entry, tags map[string]string) bool {
+	for key, value := range tags {
+		if entry.Tags[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteEntries writes the runs as a table.
+func WriteEntries(w io.Writer, entries []Entry) error {
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(table, "ID\tSAVED AT\tCOMMANDS\tRUNS\tERRORS\tTAGS")
+	for _, entry := range entries {
+		commands := []string{}
+		for i := 1; i <= len(entry.Summary.Commands); i++ {
+			commands = append(commands, entry.Summary.Commands[i].Exec)
+		}
+
+		fmt.Fprintf(table, "%d\t%s\t%s\t%d\t%d\t%s\n",
+			entry.ID,
+			entry.SavedAt.Format(time.RFC3339),
+			strings.Join(commands, ", "),
+			entry.Summary.SuccessCounter+entry.Summary.ErrorCounter,
+			entry.Summary.ErrorCounter,
+			formatTags(entry.Tags),
+		)
+	}
+
+	return table.Flush()
+}
+
+// WriteTrend writes the performance of the command over the runs as a table.
+func WriteTrend(w io.Writer, points []Point) error {
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(table, "ID\tSAVED AT\tRUNS\tMEDIAN\tP95\tTAGS")
+	for _, point := range points {
+		fmt.Fprintf(table, "%d\t%s\t%d\t%s\t%s\t%s\n",
+			point.ID,
+			point.SavedAt.Format(time.RFC3339),
+			point.RunCount,
+			point.Median,
+			point.P95,
+			formatTags(point.Tags),
+		)
+	}
+
+	return table.Flush()
+}
+
+func formatTags(tags map[string]string) string {
+	pairs := []string{}
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,202 @@
+package history_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/history"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		dir   string
+		path  string
+		store *history.Store
+	)
+
+	summary := func(exec string, median time.Duration) runner.Summary {
+		return runner.Summary{
+			Commands: map[int]runner.Command{
+				1: {Exec: exec, RunCount: 3, Statistics: &runner.Statistics{Median: median, P95: 2 * median}},
+			},
+			SuccessCounter: 2,
+			ErrorCounter:   1,
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "bender-history")
+		Expect(err).NotTo(HaveOccurred())
+
+		path = filepath.Join(dir, "nested", "history.jsonl")
+		store = history.NewStore(path)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("is empty before anything is saved", func() {
+		entries, err := store.List(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+	})
+
+	It("saves the runs with increasing IDs", func() {
+		first, err := store.Save(summary("ls", time.Millisecond), map[string]string{"branch": "main"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(first.ID).To(Equal(1))
+
+		second, err := store.Save(summary("ls", 2*time.Millisecond), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second.ID).To(Equal(2))
+
+		entries, err := history.NewStore(path).List(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].Tags).To(Equal(map[string]string{"branch": "main"}))
+		Expect(entries[0].Summary.Commands[1].Exec).To(Equal("ls"))
+		Expect(entries[1].SavedAt).NotTo(BeZero())
+	})
+
+	It("filters the runs by tags", func() {
+		_, err := store.Save(summary("ls", time.Millisecond), map[string]string{"branch": "main", "host": "ci"})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = store.Save(summary("ls", time.Millisecond), map[string]string{"branch": "dev"})
+		Expect(err).NotTo(HaveOccurred())
+
+		entries, err := store.List(map[string]string{"branch": "main"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].ID).To(Equal(1))
+	})
+
+	It("gets a run by its ID", func() {
+		_, err := store.Save(summary("ls", time.Millisecond), nil)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = store.Save(summary("cat", time.Millisecond), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		entry, err := store.Get(2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entry.Summary.Commands[1].Exec).To(Equal("cat"))
+
+		_, err = store.Get(3)
+		Expect(err).To(MatchError("run 3 not found in the history"))
+	})
+
+	It("returns the trend of a command", func() {
+		_, err := store.Save(summary("ls", time.Millisecond), map[string]string{"branch": "main"})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = store.Save(summary("cat", time.Millisecond), map[string]string{"branch": "main"})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = store.Save(summary("ls", 3*time.Millisecond), map[string]string{"branch": "main"})
+		Expect(err).NotTo(HaveOccurred())
+
+		points, err := store.Trend("ls", map[string]string{"branch": "main"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(points).To(HaveLen(2))
+		Expect(points[0].ID).To(Equal(1))
+		Expect(points[0].Median).To(Equal(time.Millisecond))
+		Expect(points[1].ID).To(Equal(3))
+		Expect(points[1].P95).To(Equal(6 * time.Millisecond))
+		Expect(points[1].RunCount).To(Equal(3))
+	})
+
+	It("returns the trend in the order of the commands of each run", func() {
+		run := summary("ls", time.Millisecond)
+		for i := 2; i <= 10; i++ {
+			run.Commands[i] = runner.Command{Exec: "ls", Statistics: &runner.Statistics{Median: time.Duration(i) * time.Millisecond}}
+		}
+		_, err := store.Save(run, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		points, err := store.Trend("ls", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(points).To(HaveLen(10))
+		for i, point := range points {
+			Expect(point.Median).To(Equal(time.Duration(i+1) * time.Millisecond))
+		}
+	})
+
+	It("saves concurrent runs without mixing them up", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				_, err := store.Save(summary("ls", time.Millisecond), nil)
+				Expect(err).NotTo(HaveOccurred())
+			}()
+		}
+		wg.Wait()
+
+		entries, err := store.List(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(10))
+		for i, entry := range entries {
+			Expect(entry.ID).To(Equal(i + 1))
+		}
+	})
+
+	Context("when the history is corrupted", func() {
+		It("fails to read it", func() {
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(path, []byte("not json\n"), 0644)).To(Succeed())
+
+			_, err := store.List(nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("corrupted history"))
+		})
+	})
+})
+
+var _ = Describe("WriteEntries", func() {
+	It("writes the runs as a table", func() {
+		buffer := bytes.NewBuffer([]byte{})
+		err := history.WriteEntries(buffer, []history.Entry{{
+			ID:      1,
+			SavedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Tags:    map[string]string{"host": "ci", "branch": "main"},
+			Summary: runner.Summary{
+				Commands:       map[int]runner.Command{1: {Exec: "ls"}, 2: {Exec: "cat"}},
+				SuccessCounter: 4,
+				ErrorCounter:   1,
+			},
+		}})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(buffer.String()).To(Equal(
+			"ID  SAVED AT              COMMANDS  RUNS  ERRORS  TAGS\n" +
+				"1   2020-01-02T03:04:05Z  ls, cat   5     1       branch=main,host=ci\n",
+		))
+	})
+})
+
+var _ = Describe("WriteTrend", func() {
+	It("writes the trend as a table", func() {
+		buffer := bytes.NewBuffer([]byte{})
+		err := history.WriteTrend(buffer, []history.Point{{
+			ID:       2,
+			SavedAt:  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			RunCount: 10,
+			Median:   time.Millisecond,
+			P95:      3 * time.Millisecond,
+		}})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(buffer.String()).To(Equal(
+			"ID  SAVED AT              RUNS  MEDIAN  P95  TAGS\n" +
+				"2   2020-01-02T03:04:05Z  10    1ms     3ms  \n",
+		))
+	})
+})
@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package history
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock of the file, released when it's closed.
+func lockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package history
+
+import "os"
+
+// lockFile does nothing on Windows, where concurrent saves aren't protected.
+func lockFile(_ *os.File) error {
+	return nil
+}
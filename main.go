@@ -4,12 +4,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/tscolari/bender/history"
+	"github.com/tscolari/bender/metrics"
+	"github.com/tscolari/bender/otlp"
+	"github.com/tscolari/bender/report"
 	"github.com/tscolari/bender/runner"
 	"github.com/urfave/cli"
 )
@@ -34,6 +41,23 @@ func main() {
 			Name:  "command",
 			Usage: "command(s) to run. May be set more than once",
 		},
+		cli.StringSliceFlag{
+			Name:  "param",
+			Usage: "expand the {{NAME}} placeholders of the commands into one command per value of the `PARAM`, as NAME=VALUES with a list or a range, e.g. threads=1,2,4 or threads=1..8:2. May be set more than once",
+		},
+		cli.StringFlag{
+			Name:  "feeder",
+			Usage: "replace the {{FIELD}} placeholders of the commands with a row of `FILE` on every run, a CSV file with a header or a JSON lines file",
+		},
+		cli.StringFlag{
+			Name:  "feeder-order",
+			Value: "circular",
+			Usage: "how the rows of the feeder are picked: sequential (each row once), circular or random",
+		},
+		cli.BoolFlag{
+			Name:  "feeder-unique",
+			Usage: "give each worker its own share of the rows of the feeder",
+		},
 		cli.BoolFlag{
 			Name:  "keep-running",
 			Usage: "run until aborted (ctrl-c)",
@@ -43,6 +67,222 @@ func main() {
 			Value: 0,
 			Usage: "interval to use between each call when using keep-running",
 		},
+		cli.StringFlag{
+			Name:  "stages",
+			Usage: "load profile as `duration:concurrency` stages, e.g. 30s:1,2m:16,5m:16,1m:0",
+		},
+		cli.BoolFlag{
+			Name:  "until-stable",
+			Usage: "run until the confidence interval of every command is narrower than the stable-width",
+		},
+		cli.Float64Flag{
+			Name:  "stable-width",
+			Value: 0.05,
+			Usage: "maximum width of the confidence interval relative to the estimate when using until-stable",
+		},
+		cli.StringFlag{
+			Name:  "stable-statistic",
+			Value: "mean",
+			Usage: "statistic to estimate when using until-stable: mean or a percentile, e.g. p95",
+		},
+		cli.IntFlag{
+			Name:  "min-runs",
+			Value: 10,
+			Usage: "minimum runs of each command when using until-stable",
+		},
+		cli.IntFlag{
+			Name:  "max-runs",
+			Value: 1000,
+			Usage: "maximum runs of all commands when using until-stable",
+		},
+		cli.Float64Flag{
+			Name:  "confidence",
+			Value: 0.95,
+			Usage: "confidence level of the confidence intervals",
+		},
+		cli.StringFlag{
+			Name:  "ci-method",
+			Value: "bootstrap",
+			Usage: "how to calculate the confidence intervals of the statistics: bootstrap or analytic",
+		},
+		cli.IntFlag{
+			Name:  "resamples",
+			Value: 1000,
+			Usage: "number of resamples when using bootstrap confidence intervals",
+		},
+		cli.Int64Flag{
+			Name:  "seed",
+			Value: 1,
+			Usage: "seed of the bootstrap resampling",
+		},
+		cli.StringFlag{
+			Name:  "outliers",
+			Value: "iqr",
+			Usage: "how to detect outliers: iqr, mad or none",
+		},
+		cli.BoolFlag{
+			Name:  "exclude-outliers",
+			Usage: "leave the outliers out of the statistics",
+		},
+		cli.Float64Flag{
+			Name:  "cv-warning",
+			Value: 0.1,
+			Usage: "warn when the coefficient of variation of a command is above this value (0 to disable)",
+		},
+		cli.IntFlag{
+			Name:  "calibrate",
+			Usage: "run a no-op command this many times before the benchmark to measure the overhead of starting a command (0 to disable)",
+		},
+		cli.StringFlag{
+			Name:  "calibrate-command",
+			Value: "true",
+			Usage: "no-op command run to measure the overhead",
+		},
+		cli.BoolFlag{
+			Name:  "subtract-overhead",
+			Usage: "subtract the median overhead measured with --calibrate from the statistics of the commands",
+		},
+		cli.BoolFlag{
+			Name:  "git-commit",
+			Usage: "record the git commit of the working directory in the metadata",
+		},
+		cli.DurationFlag{
+			Name:  "system-interval",
+			Usage: "sample the CPU, memory, load and disk usage of the host at this interval during the run (0 to disable)",
+		},
+		cli.DurationFlag{
+			Name:  "process-interval",
+			Usage: "sample the CPU, memory and IO usage of the process tree of every run at this interval (0 to disable)",
+		},
+		cli.StringFlag{
+			Name:  "cgroup",
+			Usage: "run the commands in cgroups created under the cgroup v2 `DIR`, e.g. /sys/fs/cgroup/bender",
+		},
+		cli.StringFlag{
+			Name:  "cgroup-scope",
+			Value: "run",
+			Usage: "whether each run gets its own cgroup (run) or all the runs share one (benchmark)",
+		},
+		cli.Float64Flag{
+			Name:  "cgroup-cpus",
+			Usage: "maximum number of CPUs the cgroup can use, e.g. 1.5 (0 for no limit)",
+		},
+		cli.StringFlag{
+			Name:  "cgroup-memory",
+			Usage: "maximum memory of the cgroup, in bytes or with a K, M or G suffix, e.g. 512M",
+		},
+		cli.IntFlag{
+			Name:  "cgroup-io-weight",
+			Usage: "IO weight of the cgroup, from 1 to 10000 (0 for the default)",
+		},
+		cli.StringFlag{
+			Name:  "cpus",
+			Usage: "pin the runs to the CPUs in `LIST` round-robin, e.g. 0-3,6",
+		},
+		cli.StringFlag{
+			Name:  "affinity-by",
+			Value: "worker",
+			Usage: "whether the runs are pinned to the CPUs by their worker or by their command",
+		},
+		cli.IntFlag{
+			Name:  "nice",
+			Usage: "nice value of the commands, from -20 to 19 (0 to leave it unchanged)",
+		},
+		cli.StringFlag{
+			Name:  "ionice",
+			Usage: "IO scheduling `CLASS` of the commands, with an optional level from 0 to 7: realtime, best-effort or idle, e.g. best-effort:7",
+		},
+		cli.StringFlag{
+			Name:  "trace",
+			Usage: "write the runs to `FILE` in the Chrome Trace Event format",
+		},
+		cli.StringFlag{
+			Name:  "html",
+			Usage: "write a self-contained HTML report to `FILE`",
+		},
+		cli.StringFlag{
+			Name:  "junit",
+			Usage: "write a JUnit XML report to `FILE`, with a test case for each command",
+		},
+		cli.DurationFlag{
+			Name:  "assert-mean",
+			Usage: "fail the JUnit test case of the commands with a mean duration above this value",
+		},
+		cli.DurationFlag{
+			Name:  "assert-p99",
+			Usage: "fail the JUnit test case of the commands with a p99 duration above this value",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Value: "json",
+			Usage: "output format: json, markdown or junit",
+		},
+		cli.StringFlag{
+			Name:  "baseline",
+			Usage: "compare the results with the summary in `FILE`, when using the markdown format",
+		},
+		cli.BoolFlag{
+			Name:  "save",
+			Usage: "save the summary in the history",
+		},
+		cli.StringSliceFlag{
+			Name:  "tag",
+			Usage: "`TAG` of the run saved in the history, as key=value, e.g. branch=main. May be set more than once",
+		},
+		historyFlag(),
+		cli.StringFlag{
+			Name:  "metrics-addr",
+			Usage: "serve Prometheus metrics of the runs on `ADDRESS`/metrics, e.g. :9100",
+		},
+		cli.StringSliceFlag{
+			Name:  "sink",
+			Usage: "send each run to the metrics server `SINK`, as protocol=network://address, e.g. statsd=udp://127.0.0.1:8125. May be set more than once",
+		},
+		cli.StringFlag{
+			Name:  "sink-prefix",
+			Value: "bender",
+			Usage: "prefix of the metric names sent to the sinks (measurement name for influx), where {{command}} and {{worker}} are replaced with the index of the command and worker of each run",
+		},
+		cli.StringFlag{
+			Name:  "sink-tags",
+			Usage: "`TAGS` added to the metrics sent to the sinks, e.g. env=ci,team=core, where {{command}} and {{worker}} in the values are replaced like in --sink-prefix",
+		},
+		cli.IntFlag{
+			Name:  "sink-batch",
+			Value: 20,
+			Usage: "maximum number of lines sent together to the sinks",
+		},
+		cli.DurationFlag{
+			Name:  "sink-flush",
+			Value: time.Second,
+			Usage: "how often partial batches are sent to the sinks",
+		},
+		cli.StringFlag{
+			Name:  "otlp-endpoint",
+			Usage: "export a span for every run and metrics of the runs to the OTLP/HTTP collector at `URL`, e.g. http://localhost:4318",
+		},
+		cli.StringFlag{
+			Name:  "otlp-service",
+			Value: "bender",
+			Usage: "service name of the telemetry exported to the OTLP collector",
+		},
+		cli.DurationFlag{
+			Name:  "otlp-interval",
+			Value: 10 * time.Second,
+			Usage: "how often the telemetry is exported to the OTLP collector",
+		},
+		cli.DurationFlag{
+			Name:  "bucket",
+			Value: time.Second,
+			Usage: "width of the buckets of the time series (0 to disable)",
+		},
+	}
+
+	app.Commands = []cli.Command{
+		sweepCommand(),
+		searchCommand(),
+		reportCommand(),
+		historyCommand(),
 	}
 
 	app.Action = func(c *cli.Context) error {
@@ -58,20 +298,356 @@ func main() {
 			return errors.New("can't use `--count` and `--interval` at the same time")
 		}
 
+		if c.IsSet("stages") && (c.IsSet("count") || c.Bool("keep-running") || c.IsSet("interval")) {
+			return errors.New("can't use `--stages` with `--count`, `--keep-running` or `--interval`")
+		}
+
+		if c.Bool("until-stable") && (c.IsSet("count") || c.Bool("keep-running") || c.IsSet("interval") || c.IsSet("stages")) {
+			return errors.New("can't use `--until-stable` with `--count`, `--keep-running`, `--interval` or `--stages`")
+		}
+
+		format := c.String("format")
+		if format != "json" && format != "markdown" && format != "junit" {
+			return fmt.Errorf("invalid format `%s`: use `json`, `markdown` or `junit`", format)
+		}
+
+		if c.IsSet("baseline") && format != "markdown" {
+			return errors.New("can't use `--baseline` without `--format markdown`")
+		}
+
+		if (c.IsSet("assert-mean") || c.IsSet("assert-p99")) && format != "junit" && c.String("junit") == "" {
+			return errors.New("can't use `--assert-mean` or `--assert-p99` without a JUnit report")
+		}
+
+		thresholds := report.Thresholds{
+			Mean: c.Duration("assert-mean"),
+			P99:  c.Duration("assert-p99"),
+		}
+
+		baseline, err := readBaseline(c.String("baseline"))
+		if err != nil {
+			return fmt.Errorf("Failed to read baseline: %s", err.Error())
+		}
+
+		parameters := []runner.Parameter{}
+		for _, definition := range c.StringSlice("param") {
+			parameter, err := runner.ParseParameter(definition)
+			if err != nil {
+				return err
+			}
+			parameters = append(parameters, parameter)
+		}
+
+		if c.String("feeder") == "" && (c.IsSet("feeder-order") || c.Bool("feeder-unique")) {
+			return errors.New("can't use `--feeder-order` or `--feeder-unique` without `--feeder`")
+		}
+
+		var feeder *runner.Feeder
+		fields := []string{}
+		if c.String("feeder") != "" {
+			feeder, err = runner.NewFeeder(runner.FeederOptions{
+				Path:    c.String("feeder"),
+				Order:   c.String("feeder-order"),
+				Unique:  c.Bool("feeder-unique"),
+				Workers: maxWorkers(c),
+			})
+			if err != nil {
+				return fmt.Errorf("Failed to read the feeder: %s", err.Error())
+			}
+			fields = feeder.Fields()
+		}
+
+		matrix, err := runner.ExpandMatrix(c.StringSlice("command"), parameters, fields)
+		if err != nil {
+			return err
+		}
+
+		commands := make([]string, len(matrix))
+		for i, matrixCommand := range matrix {
+			commands[i] = matrixCommand.Exec
+		}
+
+		if c.Bool("subtract-overhead") && c.Int("calibrate") <= 0 {
+			return errors.New("can't use `--subtract-overhead` without `--calibrate`")
+		}
+
+		if c.IsSet("tag") && !c.Bool("save") {
+			return errors.New("can't use `--tag` without `--save`")
+		}
+
+		tags, err := parseTags(c.StringSlice("tag"))
+		if err != nil {
+			return err
+		}
+
+		scope := c.String("cgroup-scope")
+		if scope != "run" && scope != "benchmark" {
+			return fmt.Errorf("invalid cgroup scope `%s`: use `run` or `benchmark`", scope)
+		}
+
+		affinityBy := c.String("affinity-by")
+		if affinityBy != "worker" && affinityBy != "command" {
+			return fmt.Errorf("invalid affinity `%s`: use `worker` or `command`", affinityBy)
+		}
+
+		if c.IsSet("affinity-by") && c.String("cpus") == "" {
+			return errors.New("can't use `--affinity-by` without `--cpus`")
+		}
+
+		if c.String("cgroup") == "" && (c.IsSet("cgroup-scope") || c.IsSet("cgroup-cpus") || c.IsSet("cgroup-memory") || c.IsSet("cgroup-io-weight")) {
+			return errors.New("can't use the `--cgroup-*` options without `--cgroup`")
+		}
+
 		cancelChan := make(chan bool)
 		listenForShutdown(cancelChan)
 
-		runner, err := newRunnerFromArgs(c)
+		statisticsOptions := runner.StatisticsOptions{
+			Method:          c.String("ci-method"),
+			Confidence:      c.Float64("confidence"),
+			Resamples:       c.Int("resamples"),
+			Seed:            c.Int64("seed"),
+			Outliers:        c.String("outliers"),
+			ExcludeOutliers: c.Bool("exclude-outliers"),
+			CVThreshold:     c.Float64("cv-warning"),
+		}
+		if err := statisticsOptions.Validate(); err != nil {
+			return err
+		}
+
+		benchmarkRunner, err := newRunnerFromArgs(c)
 		if err != nil {
 			return err
 		}
 
-		summary, err := runner.Run(c.Int("concurrency"), cancelChan, c.StringSlice("command")...)
+		if feeder != nil {
+			benchmarkRunner.AddHook(feeder)
+		}
+
+		if c.String("metrics-addr") != "" {
+			prometheus := metrics.NewPrometheus(nil)
+			benchmarkRunner.AddObserver(prometheus)
+
+			err = serveMetrics(c.String("metrics-addr"), prometheus)
+			if err != nil {
+				return fmt.Errorf("Failed to serve metrics: %s", err.Error())
+			}
+		}
+
+		sinks, err := newSinksFromArgs(c)
 		if err != nil {
+			return fmt.Errorf("Failed to connect to sink: %s", err.Error())
+		}
+		for _, sink := range sinks {
+			benchmarkRunner.AddObserver(sink)
+		}
+
+		if c.Duration("process-interval") > 0 {
+			processSampler, err := runner.NewProcessSampler(c.Duration("process-interval"))
+			if err != nil {
+				return err
+			}
+			benchmarkRunner.AddHook(processSampler)
+		}
+
+		var scheduler *runner.Scheduler
+		if c.String("cpus") != "" || c.Int("nice") != 0 || c.String("ionice") != "" {
+			scheduler, err = newSchedulerFromArgs(c)
+			if err != nil {
+				return err
+			}
+			benchmarkRunner.AddHook(scheduler)
+		}
+
+		var sampler *runner.SystemSampler
+		if c.Duration("system-interval") > 0 {
+			sampler, err = runner.NewSystemSampler(c.Duration("system-interval"))
+			if err != nil {
+				return err
+			}
+		}
+
+		var cgroup *runner.Cgroup
+		if c.String("cgroup") != "" {
+			cgroup, err = runner.NewCgroup(runner.CgroupOptions{
+				Parent:    c.String("cgroup"),
+				PerRun:    scope == "run",
+				CPUs:      c.Float64("cgroup-cpus"),
+				MemoryMax: c.String("cgroup-memory"),
+				IOWeight:  c.Int("cgroup-io-weight"),
+			})
+			if err != nil {
+				return fmt.Errorf("Failed to create the cgroup: %s", err.Error())
+			}
+			benchmarkRunner.AddHook(cgroup)
+		}
+
+		var overhead *runner.Overhead
+		if c.Int("calibrate") > 0 {
+			// The calibration is scheduled like the benchmark, so it measures the
+			// same overhead, but it's kept out of its cgroup and usage data.
+			calibrationRunner := runner.NewCountRunner(c.Int("calibrate"))
+			if scheduler != nil {
+				calibrationRunner.AddHook(scheduler)
+			}
+
+			overhead, err = runner.CalibrateOverhead(calibrationRunner, c.Int("concurrency"), cancelChan, c.String("calibrate-command"), statisticsOptions)
+			if err != nil {
+				if cgroup != nil {
+					cgroup.Close()
+				}
+				return fmt.Errorf("Failed to calibrate the overhead: %s", err.Error())
+			}
+		}
+
+		// The exporter is created once everything else that can fail is set up,
+		// as it exports in the background until it's closed.
+		var exporter *otlp.Exporter
+		if c.String("otlp-endpoint") != "" {
+			exporter, err = otlp.NewExporter(otlp.Options{
+				Endpoint:    c.String("otlp-endpoint"),
+				ServiceName: c.String("otlp-service"),
+				Interval:    c.Duration("otlp-interval"),
+				Commands:    commands,
+			})
+			if err != nil {
+				if cgroup != nil {
+					cgroup.Close()
+				}
+				return fmt.Errorf("Failed to export telemetry: %s", err.Error())
+			}
+			benchmarkRunner.AddHook(exporter)
+		}
+
+		metadata := runner.CollectMetadata(c.App.Version, os.Args)
+		if sampler != nil {
+			sampler.Start()
+		}
+
+		summary, err := benchmarkRunner.Run(c.Int("concurrency"), cancelChan, commands...)
+		if cgroup != nil {
+			cgroupStats, closeErr := cgroup.Close()
+			if closeErr != nil {
+				summary.Warnings = append(summary.Warnings, fmt.Sprintf("failed to remove the cgroup: %s", closeErr.Error()))
+			}
+			summary.Cgroup = cgroupStats
+		}
+		if err != nil {
+			if exporter != nil {
+				exporter.Close()
+			}
 			return fmt.Errorf("Failed to run: %s", err.Error())
 		}
 
-		err = json.NewEncoder(os.Stdout).Encode(&summary)
+		for _, parameter := range parameters {
+			summary.Parameters = append(summary.Parameters, parameter.Name)
+		}
+		for i, matrixCommand := range matrix {
+			cmd := summary.Commands[i+1]
+			cmd.Params = matrixCommand.Params
+			summary.Commands[i+1] = cmd
+		}
+
+		if scheduler != nil {
+			summary.Affinity = scheduler.Affinity()
+			if err := scheduler.Err(); err != nil {
+				summary.Warnings = append(summary.Warnings, fmt.Sprintf("some runs failed as they couldn't be scheduled: %s", err.Error()))
+			}
+		}
+
+		if sampler != nil {
+			summary.System = sampler.Stop()
+		}
+
+		metadata.LoadAverageEnd = runner.LoadAverage()
+		if c.Bool("git-commit") {
+			metadata.GitCommit, metadata.GitDirty, err = runner.GitCommit(".")
+			if err != nil {
+				summary.Warnings = append(summary.Warnings, fmt.Sprintf("failed to read the git commit: %s", err.Error()))
+			}
+		}
+		summary.Metadata = &metadata
+
+		for _, sink := range sinks {
+			if err := sink.Close(); err != nil {
+				summary.Warnings = append(summary.Warnings, fmt.Sprintf("failed to send metrics: %s", err.Error()))
+			}
+		}
+
+		if exporter != nil {
+			if err := exporter.Close(); err != nil {
+				summary.Warnings = append(summary.Warnings, fmt.Sprintf("failed to export telemetry: %s", err.Error()))
+			}
+		}
+
+		if overhead != nil {
+			if c.Bool("subtract-overhead") {
+				statisticsOptions.Overhead = overhead.Statistics.Median
+				overhead.Subtracted = true
+			}
+			summary.Overhead = overhead
+		}
+
+		err = runner.CalculateStatistics(&summary, statisticsOptions)
+		if err != nil {
+			return fmt.Errorf("Failed to run: %s", err.Error())
+		}
+
+		runner.SummarizeWorkers(&summary)
+
+		if c.Duration("bucket") > 0 {
+			err = runner.BuildTimeSeries(&summary, c.Duration("bucket"))
+			if err != nil {
+				return fmt.Errorf("Failed to run: %s", err.Error())
+			}
+		}
+
+		summary.Config = runConfig(c)
+
+		if c.String("trace") != "" {
+			err = writeFile(c.String("trace"), summary.WriteTrace)
+			if err != nil {
+				return fmt.Errorf("Failed to write trace: %s", err.Error())
+			}
+		}
+
+		if c.String("html") != "" {
+			err = writeFile(c.String("html"), func(w io.Writer) error {
+				return report.WriteHTML(w, summary)
+			})
+			if err != nil {
+				return fmt.Errorf("Failed to write HTML report: %s", err.Error())
+			}
+		}
+
+		if c.String("junit") != "" {
+			err = writeFile(c.String("junit"), func(w io.Writer) error {
+				return report.WriteJUnit(w, summary, thresholds)
+			})
+			if err != nil {
+				return fmt.Errorf("Failed to write JUnit report: %s", err.Error())
+			}
+		}
+
+		if c.Bool("save") {
+			entry, err := history.NewStore(historyPath(c)).Save(summary, tags)
+			if err != nil {
+				return fmt.Errorf("Failed to save the run: %s", err.Error())
+			}
+			fmt.Fprintf(os.Stderr, "Saved as run %d in the history\n", entry.ID)
+		}
+
+		for _, warning := range summary.Warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+		}
+
+		switch format {
+		case "markdown":
+			err = report.WriteMarkdown(os.Stdout, summary, baseline)
+		case "junit":
+			err = report.WriteJUnit(os.Stdout, summary, thresholds)
+		default:
+			err = json.NewEncoder(os.Stdout).Encode(&summary)
+		}
 		if err != nil {
 			return fmt.Errorf("Failed to run: %s", err.Error())
 		}
@@ -80,12 +656,28 @@ func main() {
 	}
 
 	if err := app.Run(os.Args); err != nil {
-		fmt.Fprintf(os.Stderr, err.Error())
+		fmt.Fprint(os.Stderr, err.Error())
 		os.Exit(1)
 	}
 
 }
 
+// metadataRunner is a Runner that describes the environment of every summary it
+// returns, for the subcommands that run their runner more than once.
+type metadataRunner struct {
+	runner.Runner
+	version string
+}
+
+func (r metadataRunner) Run(concurrency int, cancel chan bool, commands ...string) (runner.Summary, error) {
+	metadata := runner.CollectMetadata(r.version, os.Args)
+	summary, err := r.Runner.Run(concurrency, cancel, commands...)
+	metadata.LoadAverageEnd = runner.LoadAverage()
+	summary.Metadata = &metadata
+
+	return summary, err
+}
+
 func newRunnerFromArgs(c *cli.Context) (runner.Runner, error) {
 	if c.IsSet("count") {
 		return runner.NewCountRunner(c.Int("count")), nil
@@ -95,7 +687,132 @@ func newRunnerFromArgs(c *cli.Context) (runner.Runner, error) {
 		return runner.NewLoopRunner(c.Duration("interval")), nil
 	}
 
-	return nil, errors.New("no runner detected. Use `--keep-running` or `--count`")
+	if c.IsSet("stages") {
+		profile, err := runner.ParseProfile(c.String("stages"))
+		if err != nil {
+			return nil, err
+		}
+		return runner.NewStagedRunner(profile), nil
+	}
+
+	if c.Bool("until-stable") {
+		percentile, err := runner.ParseStatistic(c.String("stable-statistic"))
+		if err != nil {
+			return nil, err
+		}
+
+		return runner.NewAdaptiveRunner(runner.AdaptiveOptions{
+			Percentile:  percentile,
+			Confidence:  c.Float64("confidence"),
+			TargetWidth: c.Float64("stable-width"),
+			MinRuns:     c.Int("min-runs"),
+			MaxRuns:     c.Int("max-runs"),
+		}), nil
+	}
+
+	return nil, errors.New("no runner detected. Use `--keep-running`, `--count`, `--stages` or `--until-stable`")
+}
+
+func newSchedulerFromArgs(c *cli.Context) (*runner.Scheduler, error) {
+	var cpus []int
+	if c.String("cpus") != "" {
+		var err error
+		cpus, err = runner.ParseCPUList(c.String("cpus"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return runner.NewScheduler(runner.SchedulingOptions{
+		CPUs:       cpus,
+		ByCommand:  c.String("affinity-by") == "command",
+		Nice:       c.Int("nice"),
+		IOPriority: c.String("ionice"),
+	})
+}
+
+// maxWorkers returns the highest number of workers the runner will have.
+func maxWorkers(c *cli.Context) int {
+	if !c.IsSet("stages") {
+		return c.Int("concurrency")
+	}
+
+	profile, err := runner.ParseProfile(c.String("stages"))
+	if err != nil {
+		return c.Int("concurrency")
+	}
+
+	return profile.MaxConcurrency(c.Int("concurrency"))
+}
+
+// runConfig returns the value of every option of the run, by name.
+// The commands are left out, as they are already part of the summary.
+func runConfig(c *cli.Context) map[string]string {
+	config := map[string]string{}
+	for _, flag := range c.App.Flags {
+		name := flag.GetName()
+		value := c.Generic(name)
+		if value == nil || name == "command" {
+			continue
+		}
+
+		config[name] = fmt.Sprint(value)
+	}
+
+	return config
+}
+
+func newSinksFromArgs(c *cli.Context) ([]*metrics.Sink, error) {
+	tags, err := metrics.ParseTags(c.String("sink-tags"))
+	if err != nil {
+		return nil, err
+	}
+
+	sinks := []*metrics.Sink{}
+	for _, definition := range c.StringSlice("sink") {
+		options, err := metrics.ParseSink(definition, metrics.SinkOptions{
+			Prefix:        c.String("sink-prefix"),
+			Tags:          tags,
+			BatchSize:     c.Int("sink-batch"),
+			FlushInterval: c.Duration("sink-flush"),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		sink, err := metrics.NewSink(options)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return sinks, nil
+}
+
+// serveMetrics serves the metrics on the given address in the background,
+// until the process exits.
+func serveMetrics(addr string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	go http.Serve(listener, mux)
+
+	return nil
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return write(file)
 }
 
 func listenForShutdown(cancel chan bool) {
@@ -1,12 +1,22 @@
 package main_test
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
 	"github.com/onsi/gomega/gexec"
+	"github.com/tscolari/bender/runner"
 )
 
 var _ = Describe("Main", func() {
@@ -63,6 +73,15 @@ var _ = Describe("Main", func() {
 
 			Expect(summary.SuccessCounter).To(BeZero())
 			Expect(summary.ErrorCounter).To(Equal(3))
+			Expect(summary.EachRun[0].ExitCode).To(Equal(-1))
+		})
+
+		It("records the exit code of each run", func() {
+			summary, err := RunBender("--count", "1", "--command", "false")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.ErrorCounter).To(Equal(1))
+			Expect(summary.EachRun[0].ExitCode).To(Equal(1))
 		})
 	})
 
@@ -122,4 +141,779 @@ var _ = Describe("Main", func() {
 			})
 		})
 	})
+
+	Context("when --stages is provided", func() {
+		It("follows the load profile", func() {
+			summary, err := RunBender("--stages", "1s:1,1s:3", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.Duration).To(BeNumerically("~", 2*time.Second, 200*time.Millisecond))
+			Expect(summary.EachRun[0].Stage).To(Equal(1))
+			Expect(summary.EachRun[0].Concurrency).To(Equal(1))
+			Expect(summary.EachRun[len(summary.EachRun)-1].Stage).To(Equal(2))
+		})
+
+		Context("and the profile is invalid", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--stages", "1s", "--command", "sleep 1")
+				Expect(err).To(MatchError("invalid stage `1s`: expected `duration:concurrency`"))
+			})
+		})
+
+		Context("and --count is also provided", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--count", "3", "--stages", "1s:1", "--command", "sleep 1")
+				Expect(err).To(MatchError("can't use `--stages` with `--count`, `--keep-running` or `--interval`"))
+			})
+		})
+	})
+
+	Context("sweep", func() {
+		It("runs the workload on each concurrency level", func() {
+			sess, err := RunBenderSession("sweep", "--levels", "1,2", "--count", "4", "--command", "sleep 0.2")
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(sess, 5*time.Second).Should(gexec.Exit(0))
+
+			var report runner.SweepReport
+			Expect(json.Unmarshal(sess.Out.Contents(), &report)).To(Succeed())
+			Expect(report.Levels).To(HaveLen(2))
+			Expect(report.Levels[0].Concurrency).To(Equal(1))
+			Expect(report.Levels[0].Summary.SuccessCounter).To(Equal(4))
+			Expect(report.Levels[1].Concurrency).To(Equal(2))
+			Expect(report.Levels[1].Throughput).To(BeNumerically("~", 2*report.Levels[0].Throughput, report.Levels[0].Throughput/2))
+
+			for _, level := range report.Levels {
+				Expect(level.Summary.Metadata).NotTo(BeNil())
+				Expect(level.Summary.Metadata.Invocation[1]).To(Equal("sweep"))
+			}
+		})
+
+		Context("when --format table is provided", func() {
+			It("prints a table", func() {
+				sess, err := RunBenderSession("sweep", "--levels", "1-2", "--count", "2", "--format", "table", "--command", "sleep 0.1")
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(sess, 5*time.Second).Should(gexec.Exit(0))
+
+				Expect(sess.Out).To(gbytes.Say("CONCURRENCY"))
+				Expect(sess.Out).To(gbytes.Say("knee: "))
+			})
+		})
+	})
+
+	Context("search", func() {
+		It("finds the highest rate that meets the SLO", func() {
+			sess, err := RunBenderSession("search", "--min-rate", "1", "--max-rate", "20", "--tolerance", "2",
+				"--trial-duration", "500ms", "--concurrency", "1", "--slo-p99", "500ms", "--command", "sleep 0.2")
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(sess, 10*time.Second).Should(gexec.Exit(0))
+
+			var result runner.SearchResult
+			Expect(json.Unmarshal(sess.Out.Contents(), &result)).To(Succeed())
+			Expect(result.MaxRate).To(BeNumerically(">=", 1))
+			Expect(result.MaxRate).To(BeNumerically("<", 6))
+			Expect(result.Trials[0].Rate).To(Equal(1.0))
+			Expect(result.Trials[0].Passed).To(BeTrue())
+			for _, trial := range result.Trials {
+				Expect(trial.Summary.Metadata).NotTo(BeNil())
+				Expect(trial.Summary.Metadata.CPUCount).To(BeNumerically(">", 0))
+			}
+		})
+	})
+
+	Context("when --until-stable is provided", func() {
+		It("runs until the precision is reached", func() {
+			summary, err := RunBender("--until-stable", "--stable-width", "0.5", "--min-runs", "5", "--max-runs", "50", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.SuccessCounter).To(BeNumerically(">=", 5))
+			Expect(summary.SuccessCounter).To(BeNumerically("<", 50))
+			Expect(summary.Commands[1].Precision.Statistic).To(Equal("mean"))
+			Expect(summary.Commands[1].Precision.Stable).To(BeTrue())
+		})
+
+		Context("and the statistic is invalid", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--until-stable", "--stable-statistic", "avg", "--command", "sleep 0.1")
+				Expect(err).To(MatchError("invalid statistic `avg`: use `mean` or a percentile such as `p95`"))
+			})
+		})
+
+		Context("and --count is also provided", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--count", "3", "--until-stable", "--command", "sleep 0.1")
+				Expect(err).To(MatchError("can't use `--until-stable` with `--count`, `--keep-running`, `--interval` or `--stages`"))
+			})
+		})
+	})
+
+	Context("statistics", func() {
+		It("includes the statistics and confidence intervals of each command", func() {
+			summary, err := RunBender("--count", "10", "--concurrency", "5", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			statistics := summary.Commands[1].Statistics
+			Expect(statistics).NotTo(BeNil())
+			Expect(statistics.Median).To(BeNumerically("~", 100*time.Millisecond, 50*time.Millisecond))
+			Expect(statistics.MeanCI.Confidence).To(Equal(0.95))
+			Expect(statistics.MeanCI.Lower).To(BeNumerically("<=", statistics.Mean))
+			Expect(statistics.MeanCI.Upper).To(BeNumerically(">=", statistics.Mean))
+			Expect(statistics.MedianCI.Lower).To(BeNumerically("<=", statistics.Median))
+			Expect(statistics.MedianCI.Upper).To(BeNumerically(">=", statistics.Median))
+		})
+
+		Context("when --outliers is invalid", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--count", "1", "--outliers", "zscore", "--command", "sleep 0.1")
+				Expect(err).To(MatchError("invalid outliers method: use `iqr`, `mad` or `none`"))
+			})
+		})
+
+		Context("when --ci-method is invalid", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--count", "1", "--ci-method", "magic", "--command", "sleep 0.1")
+				Expect(err).To(MatchError("invalid method: use `bootstrap` or `analytic`"))
+			})
+		})
+	})
+
+	Context("time series", func() {
+		It("buckets the runs by the time they finished", func() {
+			summary, err := RunBender("--count", "3", "--bucket", "500ms", "--command", "sleep 0.4")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.TimeSeries).To(HaveLen(3))
+			completed := 0
+			for i, bucket := range summary.TimeSeries {
+				Expect(bucket.Start).To(Equal(time.Duration(i) * 500 * time.Millisecond))
+				completed += bucket.Completed
+			}
+			Expect(completed).To(Equal(3))
+			Expect(summary.TimeSeries[0].Commands[1].Completed).To(Equal(1))
+		})
+
+		Context("when --bucket is 0", func() {
+			It("doesn't include the time series", func() {
+				summary, err := RunBender("--count", "1", "--bucket", "0", "--command", "sleep 0.1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(summary.TimeSeries).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("workers", func() {
+		It("breaks down the runs per worker", func() {
+			summary, err := RunBender("--count", "4", "--concurrency", "2", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.Workers).To(HaveLen(2))
+			Expect(summary.Workers[1].Runs + summary.Workers[2].Runs).To(Equal(4))
+			for _, runStats := range summary.EachRun {
+				Expect(runStats.Worker).To(BeElementOf(1, 2))
+				Expect(runStats.Iteration).To(BeNumerically(">=", 1))
+			}
+		})
+	})
+
+	Context("metadata", func() {
+		It("describes the environment of the run", func() {
+			summary, err := RunBender("--count", "1", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.Metadata).NotTo(BeNil())
+			Expect(summary.Metadata.Version).To(Equal("1.0.0"))
+			Expect(summary.Metadata.CPUCount).To(BeNumerically(">", 0))
+			Expect(summary.Metadata.Invocation).To(Equal([]string{BenderBinPath, "--count", "1", "--command", "sleep 0.1"}))
+			Expect(summary.Metadata.GitCommit).To(BeEmpty())
+		})
+	})
+
+	Context("when --system-interval is provided", func() {
+		It("samples the usage of the host during the run", func() {
+			summary, err := RunBender("--count", "1", "--system-interval", "50ms", "--command", "sleep 0.5")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.System).NotTo(BeEmpty())
+			Expect(summary.System[0].MemoryUsed).To(BeNumerically(">", 0))
+		})
+	})
+
+	Context("when --process-interval is provided", func() {
+		It("samples the usage of the process tree of every run", func() {
+			summary, err := RunBender("--count", "2", "--process-interval", "20ms", "--command", "sleep 0.2")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.EachRun).To(HaveLen(2))
+			for _, runStats := range summary.EachRun {
+				Expect(runStats.Process).NotTo(BeNil())
+				Expect(runStats.Process.PeakProcesses).To(Equal(1))
+				Expect(runStats.Process.PeakRSS).To(BeNumerically(">", 0))
+				Expect(runStats.Process.Samples).NotTo(BeEmpty())
+			}
+		})
+	})
+
+	Context("when --cgroup is provided", func() {
+		var parent string
+
+		BeforeEach(func() {
+			mount, err := runner.CgroupMount()
+			if err != nil {
+				Skip(err.Error())
+			}
+
+			parent = filepath.Join(mount, "bender-main-test-"+strconv.Itoa(os.Getpid()))
+			if err := os.Mkdir(parent, 0755); err != nil {
+				Skip("can't create a cgroup v2")
+			}
+		})
+
+		AfterEach(func() {
+			Expect(os.Remove(parent)).To(Succeed())
+		})
+
+		It("runs every command in its own cgroup and records its usage", func() {
+			summary, err := RunBender("--count", "2", "--cgroup", parent, "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, runStats := range summary.EachRun {
+				Expect(runStats.Failed).To(BeFalse())
+				Expect(runStats.Cgroup).NotTo(BeNil())
+			}
+			Expect(summary.Cgroup).To(BeNil())
+		})
+
+		It("records the usage of the benchmark's cgroup with `--cgroup-scope benchmark`", func() {
+			summary, err := RunBender("--count", "2", "--cgroup", parent, "--cgroup-scope", "benchmark", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.EachRun[0].Cgroup).To(BeNil())
+			Expect(summary.Cgroup).NotTo(BeNil())
+		})
+
+		Context("when the scope is invalid", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--count", "1", "--cgroup", parent, "--cgroup-scope", "all", "--command", "sleep 0.1")
+				Expect(err).To(MatchError("invalid cgroup scope `all`: use `run` or `benchmark`"))
+			})
+		})
+	})
+
+	Context("when a cgroup limit is provided without --cgroup", func() {
+		It("fails to run", func() {
+			_, err := RunBender("--count", "1", "--cgroup-cpus", "1", "--command", "sleep 0.1")
+			Expect(err).To(MatchError("can't use the `--cgroup-*` options without `--cgroup`"))
+		})
+	})
+
+	Context("when --param is provided", func() {
+		It("benchmarks every combination of the parameters", func() {
+			summary, err := RunBender("--count", "8", "--param", "time=0.1,0.2", "--param", "unit=1..2",
+				"--command", "sleep {{time}}", "--command", "echo {{unit}}")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.Parameters).To(Equal([]string{"time", "unit"}))
+			Expect(summary.Commands).To(HaveLen(4))
+			Expect(summary.Commands[1].Exec).To(Equal("sleep 0.1"))
+			Expect(summary.Commands[1].Params).To(Equal(map[string]string{"time": "0.1"}))
+			Expect(summary.Commands[2].Exec).To(Equal("sleep 0.2"))
+			Expect(summary.Commands[3].Exec).To(Equal("echo 1"))
+			Expect(summary.Commands[4].Params).To(Equal(map[string]string{"unit": "2"}))
+			Expect(summary.SuccessCounter).To(Equal(8))
+		})
+
+		Context("when a command uses an unknown placeholder", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--count", "1", "--param", "time=0.1", "--command", "sleep {{time}}{{unit}}")
+				Expect(err).To(MatchError("command `sleep {{time}}{{unit}}` uses `{{unit}}`, which isn't a parameter, a field or a variable"))
+			})
+		})
+	})
+
+	Context("when --feeder is provided", func() {
+		var feederDir string
+
+		BeforeEach(func() {
+			var err error
+			feederDir, err = ioutil.TempDir("", "bender-feeder")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(feederDir)).To(Succeed())
+		})
+
+		It("feeds the commands with the rows of the file", func() {
+			feederPath := filepath.Join(feederDir, "rows.csv")
+			Expect(ioutil.WriteFile(feederPath, []byte("time,unit\n0.1,1\n0.2,2\n"), 0644)).To(Succeed())
+
+			summary, err := RunBender("--count", "3", "--feeder", feederPath, "--feeder-order", "sequential",
+				"--param", "scale=1", "--command", "sleep {{time}} {{scale}}")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.Commands[1].Exec).To(Equal("sleep {{time}} 1"))
+			Expect(summary.SuccessCounter).To(Equal(2))
+			Expect(summary.ErrorCounter).To(Equal(1))
+			Expect(summary.EachRun[0].Row).To(Equal(1))
+			Expect(summary.EachRun[1].Row).To(Equal(2))
+		})
+
+		Context("when the file doesn't exist", func() {
+			It("fails to run", func() {
+				feederPath := filepath.Join(feederDir, "missing.csv")
+				_, err := RunBender("--count", "1", "--feeder", feederPath, "--command", "sleep {{time}}")
+				Expect(err).To(MatchError("Failed to read the feeder: open " + feederPath + ": no such file or directory"))
+			})
+		})
+	})
+
+	Context("when --feeder-order is provided without --feeder", func() {
+		It("fails to run", func() {
+			_, err := RunBender("--count", "1", "--feeder-order", "random", "--command", "sleep 0")
+			Expect(err).To(MatchError("can't use `--feeder-order` or `--feeder-unique` without `--feeder`"))
+		})
+	})
+
+	Context("when a command uses the variables", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "bender-variables")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("replaces them and exposes them in the environment on every run", func() {
+			scriptPath := filepath.Join(dir, "run.sh")
+			script := "#!/bin/sh\necho \"$BENDER_WORKER $BENDER_ITERATION $BENDER_COMMAND\" > \"$1\"\n"
+			Expect(ioutil.WriteFile(scriptPath, []byte(script), 0755)).To(Succeed())
+
+			_, err := RunBender("--count", "4", "--concurrency", "2",
+				"--command", scriptPath+" "+filepath.Join(dir, "{{worker}}-{{iteration}}.out"))
+			Expect(err).NotTo(HaveOccurred())
+
+			outputs, err := filepath.Glob(filepath.Join(dir, "*.out"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outputs).To(HaveLen(4))
+			for _, output := range outputs {
+				contents, err := ioutil.ReadFile(output)
+				Expect(err).NotTo(HaveOccurred())
+
+				name := strings.TrimSuffix(filepath.Base(output), ".out")
+				Expect(string(contents)).To(Equal(strings.Replace(name, "-", " ", 1) + " 1\n"))
+			}
+		})
+	})
+
+	Context("when --calibrate is provided", func() {
+		It("measures the overhead of starting a command", func() {
+			summary, err := RunBender("--count", "2", "--calibrate", "10", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.Overhead).NotTo(BeNil())
+			Expect(summary.Overhead.Command).To(Equal("true"))
+			Expect(summary.Overhead.Runs).To(Equal(10))
+			Expect(summary.Overhead.Statistics.Median).To(BeNumerically(">", 0))
+			Expect(summary.Overhead.Subtracted).To(BeFalse())
+			Expect(summary.Commands[1].Statistics.OverheadSubtracted).To(BeZero())
+			Expect(summary.SuccessCounter).To(Equal(2))
+		})
+
+		Context("and --subtract-overhead is provided", func() {
+			It("subtracts the overhead from the statistics", func() {
+				summary, err := RunBender("--count", "2", "--calibrate", "10", "--subtract-overhead", "--command", "sleep 0.1")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(summary.Overhead.Subtracted).To(BeTrue())
+				statistics := summary.Commands[1].Statistics
+				Expect(statistics.OverheadSubtracted).To(Equal(summary.Overhead.Statistics.Median))
+				for _, runStats := range summary.EachRun {
+					Expect(statistics.Min).To(BeNumerically("<=", runStats.Duration-summary.Overhead.Statistics.Median))
+				}
+			})
+		})
+
+		Context("when the calibration command fails", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--count", "1", "--calibrate", "2", "--calibrate-command", "false", "--command", "sleep 0.1")
+				Expect(err).To(MatchError("Failed to calibrate the overhead: 2 of 2 runs of `false` failed"))
+			})
+		})
+	})
+
+	Context("when --subtract-overhead is provided without --calibrate", func() {
+		It("fails to run", func() {
+			_, err := RunBender("--count", "1", "--subtract-overhead", "--command", "sleep 0.1")
+			Expect(err).To(MatchError("can't use `--subtract-overhead` without `--calibrate`"))
+		})
+	})
+
+	Context("when --cpus is provided", func() {
+		It("pins the runs and records the affinity", func() {
+			summary, err := RunBender("--count", "2", "--concurrency", "2", "--cpus", "0", "--nice", "5", "--ionice", "best-effort:7", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.ErrorCounter).To(BeZero())
+			Expect(summary.EachRun[0].Affinity).To(Equal([]int{0}))
+			Expect(summary.Affinity).To(Equal(&runner.Affinity{
+				By:   "worker",
+				CPUs: map[int][]int{1: {0}, 2: {0}},
+			}))
+		})
+
+		Context("when the CPU list is invalid", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--count", "1", "--cpus", "0-", "--command", "sleep 0.1")
+				Expect(err).To(MatchError("invalid CPU list `0-`: expected CPUs or ranges such as `0-3,6`"))
+			})
+		})
+
+		Context("when the affinity is invalid", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--count", "1", "--cpus", "0", "--affinity-by", "host", "--command", "sleep 0.1")
+				Expect(err).To(MatchError("invalid affinity `host`: use `worker` or `command`"))
+			})
+		})
+	})
+
+	Context("when --affinity-by is provided without --cpus", func() {
+		It("fails to run", func() {
+			_, err := RunBender("--count", "1", "--affinity-by", "command", "--command", "sleep 0.1")
+			Expect(err).To(MatchError("can't use `--affinity-by` without `--cpus`"))
+		})
+	})
+
+	Context("when --trace is provided", func() {
+		var traceDir string
+
+		BeforeEach(func() {
+			var err error
+			traceDir, err = ioutil.TempDir("", "bender-trace")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(traceDir)).To(Succeed())
+		})
+
+		It("writes the runs in the Chrome Trace Event format", func() {
+			tracePath := filepath.Join(traceDir, "trace.json")
+			_, err := RunBender("--count", "2", "--concurrency", "2", "--trace", tracePath, "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			contents, err := ioutil.ReadFile(tracePath)
+			Expect(err).NotTo(HaveOccurred())
+
+			var trace struct {
+				TraceEvents []struct {
+					Name  string `json:"name"`
+					Phase string `json:"ph"`
+				} `json:"traceEvents"`
+			}
+			Expect(json.Unmarshal(contents, &trace)).To(Succeed())
+
+			runs := 0
+			for _, event := range trace.TraceEvents {
+				if event.Phase == "X" {
+					Expect(event.Name).To(Equal("sleep 0.1"))
+					runs++
+				}
+			}
+			Expect(runs).To(Equal(2))
+		})
+	})
+
+	Context("html report", func() {
+		var reportDir string
+
+		BeforeEach(func() {
+			var err error
+			reportDir, err = ioutil.TempDir("", "bender-report")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(reportDir)).To(Succeed())
+		})
+
+		It("records the configuration of the run in the summary", func() {
+			summary, err := RunBender("--count", "2", "--concurrency", "2", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.Config).To(HaveKeyWithValue("count", "2"))
+			Expect(summary.Config).To(HaveKeyWithValue("concurrency", "2"))
+			Expect(summary.Config).NotTo(HaveKey("command"))
+		})
+
+		It("writes the report when --html is provided", func() {
+			reportPath := filepath.Join(reportDir, "report.html")
+			_, err := RunBender("--count", "2", "--html", reportPath, "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			contents, err := ioutil.ReadFile(reportPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(HavePrefix("<!DOCTYPE html>"))
+			Expect(string(contents)).To(ContainSubstring("sleep 0.1"))
+		})
+
+		It("generates the report from a summary file", func() {
+			session, err := RunBenderSession("--count", "2", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, 5*time.Second).Should(gexec.Exit(0))
+
+			summaryPath := filepath.Join(reportDir, "summary.json")
+			Expect(ioutil.WriteFile(summaryPath, session.Out.Contents(), 0644)).To(Succeed())
+
+			reportPath := filepath.Join(reportDir, "report.html")
+			session, err = RunBenderSession("report", summaryPath, "-o", reportPath)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, 5*time.Second).Should(gexec.Exit(0))
+
+			contents, err := ioutil.ReadFile(reportPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(ContainSubstring("sleep 0.1"))
+			Expect(string(contents)).To(ContainSubstring("<th>count</th>"))
+		})
+
+		Context("when the summary file doesn't exist", func() {
+			It("fails to generate the report", func() {
+				session, err := RunBenderSession("report", filepath.Join(reportDir, "missing.json"))
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session, 5*time.Second).Should(gexec.Exit(1))
+				Expect(session.Err).To(gbytes.Say("Failed to read summary"))
+			})
+		})
+	})
+
+	Context("markdown", func() {
+		It("writes the summary as markdown when --format markdown is provided", func() {
+			session, err := RunBenderSession("--count", "2", "--format", "markdown", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, 5*time.Second).Should(gexec.Exit(0))
+
+			Expect(session.Out).To(gbytes.Say(`\| # \| Command \| Runs \|`))
+			Expect(session.Out).To(gbytes.Say("\\| 1 \\| `sleep 0.1` \\| 2 \\| 0 \\|"))
+		})
+
+		It("compares the results with the --baseline", func() {
+			reportDir, err := ioutil.TempDir("", "bender-baseline")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(reportDir)
+
+			session, err := RunBenderSession("--count", "2", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, 5*time.Second).Should(gexec.Exit(0))
+
+			baselinePath := filepath.Join(reportDir, "baseline.json")
+			Expect(ioutil.WriteFile(baselinePath, session.Out.Contents(), 0644)).To(Succeed())
+
+			session, err = RunBenderSession("report", "--format", "markdown", "--baseline", baselinePath, baselinePath)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, 5*time.Second).Should(gexec.Exit(0))
+
+			Expect(session.Out).To(gbytes.Say(`\| Command \| Baseline mean \|`))
+			Expect(session.Out).To(gbytes.Say(`no significant change`))
+		})
+
+		Context("when the format is invalid", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--count", "1", "--format", "xml", "--command", "sleep 0.1")
+				Expect(err).To(MatchError("invalid format `xml`: use `json`, `markdown` or `junit`"))
+			})
+		})
+
+		Context("when --baseline is provided without --format markdown", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--count", "1", "--baseline", "summary.json", "--command", "sleep 0.1")
+				Expect(err).To(MatchError("can't use `--baseline` without `--format markdown`"))
+			})
+		})
+	})
+
+	Context("junit", func() {
+		It("writes a JUnit report when --format junit is provided", func() {
+			session, err := RunBenderSession("--count", "2", "--format", "junit", "--command", "ls /nonexistent-bender-dir")
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, 5*time.Second).Should(gexec.Exit(0))
+
+			Expect(session.Out).To(gbytes.Say(`<testsuites name="bender" tests="1" failures="1"`))
+			Expect(session.Out).To(gbytes.Say(`<testcase name="ls /nonexistent-bender-dir" classname="bender"`))
+			Expect(session.Out).To(gbytes.Say(`<failure message="2 of 2 runs failed" type="failed runs">`))
+			Expect(session.Out).To(gbytes.Say(`nonexistent-bender-dir`))
+		})
+
+		It("writes the report to --junit, failing the commands above the thresholds", func() {
+			reportDir, err := ioutil.TempDir("", "bender-junit")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(reportDir)
+
+			reportPath := filepath.Join(reportDir, "junit.xml")
+			summary, err := RunBender("--count", "2", "--junit", reportPath, "--assert-mean", "10ms", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.SuccessCounter).To(Equal(2))
+
+			contents, err := ioutil.ReadFile(reportPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(ContainSubstring(`<testcase name="sleep 0.1"`))
+			Expect(string(contents)).To(ContainSubstring(`is above the threshold of 10ms" type="threshold">`))
+		})
+
+		Context("when a threshold is provided without a JUnit report", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--count", "1", "--assert-p99", "1s", "--command", "sleep 0.1")
+				Expect(err).To(MatchError("can't use `--assert-mean` or `--assert-p99` without a JUnit report"))
+			})
+		})
+	})
+
+	Context("history", func() {
+		var (
+			historyDir  string
+			historyPath string
+		)
+
+		BeforeEach(func() {
+			var err error
+			historyDir, err = ioutil.TempDir("", "bender-history")
+			Expect(err).NotTo(HaveOccurred())
+			historyPath = filepath.Join(historyDir, "history.jsonl")
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(historyDir)).To(Succeed())
+		})
+
+		It("saves the runs and queries them", func() {
+			_, err := RunBender("--count", "2", "--save", "--tag", "branch=main", "--history", historyPath, "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = RunBender("--count", "2", "--save", "--tag", "branch=dev", "--history", historyPath, "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			session, err := RunBenderSession("history", "list", "--history", historyPath)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, 5*time.Second).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say(`ID\s+SAVED AT\s+COMMANDS\s+RUNS\s+ERRORS\s+TAGS`))
+			Expect(session.Out).To(gbytes.Say(`1\s+\S+\s+sleep 0.1\s+2\s+0\s+branch=main`))
+			Expect(session.Out).To(gbytes.Say(`2\s+\S+\s+sleep 0.1\s+2\s+0\s+branch=dev`))
+
+			session, err = RunBenderSession("history", "show", "--history", historyPath, "2")
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, 5*time.Second).Should(gexec.Exit(0))
+			summary := OutputToSummary(session.Out.Contents())
+			Expect(summary.SuccessCounter).To(Equal(2))
+
+			session, err = RunBenderSession("history", "trend", "--history", historyPath, "--tag", "branch=main", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, 5*time.Second).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say(`ID\s+SAVED AT\s+RUNS\s+MEDIAN\s+P95\s+TAGS`))
+			Expect(session.Out).To(gbytes.Say(`1\s+\S+\s+2\s+\S+\s+\S+\s+branch=main\n`))
+			Expect(session.Out).NotTo(gbytes.Say(`branch=dev`))
+		})
+
+		Context("when the run isn't in the history", func() {
+			It("fails to show it", func() {
+				session, err := RunBenderSession("history", "show", "--history", historyPath, "1")
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session, 5*time.Second).Should(gexec.Exit(1))
+				Expect(session.Err).To(gbytes.Say("run 1 not found in the history"))
+			})
+		})
+
+		Context("when --tag is provided without --save", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--count", "1", "--tag", "branch=main", "--command", "sleep 0.1")
+				Expect(err).To(MatchError("can't use `--tag` without `--save`"))
+			})
+		})
+	})
+
+	Context("when --metrics-addr is provided", func() {
+		var addr string
+
+		BeforeEach(func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			addr = listener.Addr().String()
+			Expect(listener.Close()).To(Succeed())
+		})
+
+		It("serves the metrics of the runs while running", func() {
+			sess, err := RunBenderSession("--keep-running", "--metrics-addr", addr, "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+			defer func() {
+				sess.Terminate()
+				Eventually(sess).Should(gexec.Exit(0))
+			}()
+
+			scrape := func() string {
+				response, err := http.Get("http://" + addr + "/metrics")
+				if err != nil {
+					return ""
+				}
+				defer response.Body.Close()
+
+				body, _ := ioutil.ReadAll(response.Body)
+				return string(body)
+			}
+
+			Eventually(scrape, 5*time.Second).Should(ContainSubstring(`bender_runs_total{command="sleep 0.1"}`))
+			Expect(scrape()).To(ContainSubstring(`bender_run_duration_seconds_bucket{command="sleep 0.1",le="0.25"}`))
+		})
+	})
+
+	Context("when --sink is provided", func() {
+		It("sends each run to the sink", func() {
+			listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer listener.Close()
+
+			_, err = RunBender("--count", "2", "--sink", "statsd=udp://"+listener.LocalAddr().String(), "--sink-tags", "env=test", "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			buffer := make([]byte, 65536)
+			Expect(listener.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+			n, _, err := listener.ReadFrom(buffer)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(buffer[:n])).To(ContainSubstring("bender.sleep_0_1.runs:1|c|#env:test\n"))
+			Expect(strings.Count(string(buffer[:n]), ".duration:")).To(Equal(2))
+		})
+
+		Context("when the sink is invalid", func() {
+			It("fails to run", func() {
+				_, err := RunBender("--count", "1", "--sink", "statsd:8125", "--command", "sleep 0.1")
+				Expect(err).To(MatchError("Failed to connect to sink: invalid sink `statsd:8125`: expected `protocol=network://address`"))
+			})
+		})
+	})
+
+	Context("when --otlp-endpoint is provided", func() {
+		It("exports a span for each run to the collector", func() {
+			paths := make(chan string, 10)
+			collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				paths <- r.URL.Path + " " + string(body)
+			}))
+			defer collector.Close()
+
+			_, err := RunBender("--count", "2", "--otlp-endpoint", collector.URL, "--command", "sleep 0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			requests := map[string]string{}
+			for i := 0; i < 2; i++ {
+				var request string
+				Eventually(paths).Should(Receive(&request))
+				parts := strings.SplitN(request, " ", 2)
+				requests[parts[0]] = parts[1]
+			}
+
+			Expect(requests).To(HaveKey("/v1/traces"))
+			Expect(strings.Count(requests["/v1/traces"], `"name":"sleep 0.1"`)).To(Equal(2))
+			Expect(requests["/v1/traces"]).To(ContainSubstring(`"name":"bender"`))
+			Expect(requests).To(HaveKey("/v1/metrics"))
+		})
+	})
 })
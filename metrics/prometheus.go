@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tscolari/bender/runner"
+)
+
+// DefaultBuckets are the upper bounds (in seconds) of the duration histogram
+// buckets, the same as the Prometheus client defaults.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Prometheus is a runner.Observer that keeps counters of the runs and errors,
+// and a histogram of the durations, of each command. It serves them in the
+// Prometheus text exposition format.
+type Prometheus struct {
+	mutex    sync.Mutex
+	buckets  []float64
+	commands map[string]*commandMetrics
+}
+
+type commandMetrics struct {
+	runs    uint64
+	errors  uint64
+	buckets []uint64
+	sum     float64
+}
+
+// Creates a new instance of Prometheus, with the given histogram buckets
+// (upper bounds in seconds, in ascending order).
+// If no buckets are given, DefaultBuckets are used.
+func NewPrometheus(buckets []float64) *Prometheus {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	return &Prometheus{
+		buckets:  buckets,
+		commands: map[string]*commandMetrics{},
+	}
+}
+
+// Observe records a finished run.
+func (p *Prometheus) Observe(command string, stats runner.RunStats) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	metrics, ok := p.commands[command]
+	if !ok {
+		metrics = &commandMetrics{buckets: make([]uint64, len(p.buckets))}
+		p.commands[command] = metrics
+	}
+
+	metrics.runs++
+	if stats.Failed {
+		metrics.errors++
+	}
+
+	seconds := stats.Duration.Seconds()
+	metrics.sum += seconds
+	for i, bound := range p.buckets {
+		if seconds <= bound {
+			metrics.buckets[i]++
+		}
+	}
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteText(w)
+}
+
+// WriteText writes the metrics in the Prometheus text exposition format.
+func (p *Prometheus) WriteText(w io.Writer) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	names := []string{}
+	for command := range p.commands {
+		names = append(names, command)
+	}
+	sort.Strings(names)
+
+	out := &strings.Builder{}
+
+	out.WriteString("# HELP bender_runs_total Number of finished runs.\n")
+	out.WriteString("# TYPE bender_runs_total counter\n")
+	for _, command := range names {
+		fmt.Fprintf(out, "bender_runs_total{command=\"%s\"} %d\n", escapeLabel(command), p.commands[command].runs)
+	}
+
+	out.WriteString("# HELP bender_errors_total Number of failed runs.\n")
+	out.WriteString("# TYPE bender_errors_total counter\n")
+	for _, command := range names {
+		fmt.Fprintf(out, "bender_errors_total{command=\"%s\"} %d\n", escapeLabel(command), p.commands[command].errors)
+	}
+
+	out.WriteString("# HELP bender_run_duration_seconds Duration of the runs.\n")
+	out.WriteString("# TYPE bender_run_duration_seconds histogram\n")
+	for _, command := range names {
+		metrics := p.commands[command]
+		label := escapeLabel(command)
+
+		for i, bound := range p.buckets {
+			fmt.Fprintf(out, "bender_run_duration_seconds_bucket{command=\"%s\",le=\"%s\"} %d\n", label, formatFloat(bound), metrics.buckets[i])
+		}
+		fmt.Fprintf(out, "bender_run_duration_seconds_bucket{command=\"%s\",le=\"+Inf\"} %d\n", label, metrics.runs)
+		fmt.Fprintf(out, "bender_run_duration_seconds_sum{command=\"%s\"} %s\n", label, formatFloat(metrics.sum))
+		fmt.Fprintf(out, "bender_run_duration_seconds_count{command=\"%s\"} %d\n", label, metrics.runs)
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// escapeLabel escapes a label value as required by the exposition format.
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
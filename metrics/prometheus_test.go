@@ -0,0 +1,85 @@
+package metrics_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/metrics"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("Prometheus", func() {
+	var prometheus *metrics.Prometheus
+
+	BeforeEach(func() {
+		prometheus = metrics.NewPrometheus([]float64{0.1, 1})
+
+		prometheus.Observe("ls", runner.RunStats{Duration: 50 * time.Millisecond})
+		prometheus.Observe("ls", runner.RunStats{Duration: 500 * time.Millisecond, Failed: true})
+		prometheus.Observe("ls", runner.RunStats{Duration: 2 * time.Second})
+		prometheus.Observe(`echo "hi"`, runner.RunStats{Duration: time.Millisecond})
+	})
+
+	writeText := func() string {
+		buffer := bytes.NewBuffer([]byte{})
+		Expect(prometheus.WriteText(buffer)).To(Succeed())
+		return buffer.String()
+	}
+
+	It("counts the runs and errors of each command", func() {
+		output := writeText()
+
+		Expect(output).To(ContainSubstring("# TYPE bender_runs_total counter\n"))
+		Expect(output).To(ContainSubstring("bender_runs_total{command=\"ls\"} 3\n"))
+		Expect(output).To(ContainSubstring("# TYPE bender_errors_total counter\n"))
+		Expect(output).To(ContainSubstring("bender_errors_total{command=\"ls\"} 1\n"))
+	})
+
+	It("keeps a cumulative histogram of the durations of each command", func() {
+		output := writeText()
+
+		Expect(output).To(ContainSubstring("# TYPE bender_run_duration_seconds histogram\n"))
+		Expect(output).To(ContainSubstring(
+			"bender_run_duration_seconds_bucket{command=\"ls\",le=\"0.1\"} 1\n" +
+				"bender_run_duration_seconds_bucket{command=\"ls\",le=\"1\"} 2\n" +
+				"bender_run_duration_seconds_bucket{command=\"ls\",le=\"+Inf\"} 3\n" +
+				"bender_run_duration_seconds_sum{command=\"ls\"} 2.55\n" +
+				"bender_run_duration_seconds_count{command=\"ls\"} 3\n",
+		))
+	})
+
+	It("escapes the command labels", func() {
+		Expect(writeText()).To(ContainSubstring(`bender_runs_total{command="echo \"hi\""} 1`))
+	})
+
+	It("serves the metrics over HTTP", func() {
+		server := httptest.NewServer(prometheus)
+		defer server.Close()
+
+		response, err := http.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		defer response.Body.Close()
+
+		Expect(response.Header.Get("Content-Type")).To(HavePrefix("text/plain; version=0.0.4"))
+		body, err := ioutil.ReadAll(response.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(Equal(writeText()))
+	})
+
+	Context("when no buckets are given", func() {
+		It("uses the default buckets", func() {
+			prometheus = metrics.NewPrometheus(nil)
+			prometheus.Observe("ls", runner.RunStats{Duration: time.Millisecond})
+
+			output := writeText()
+			for _, bound := range []string{"0.005", "0.25", "10"} {
+				Expect(output).To(ContainSubstring("bender_run_duration_seconds_bucket{command=\"ls\",le=\"" + bound + "\"} 1\n"))
+			}
+		})
+	})
+})
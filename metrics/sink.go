@@ -0,0 +1,305 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tscolari/bender/runner"
+)
+
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// How many full batches can wait to be sent before the next ones are dropped.
+const sinkQueueSize = 100
+
+// Defines where and how a Sink sends the runs
+// - Protocol is the format of the metrics: "statsd", "graphite" or "influx"
+// - Network is either "udp" or "tcp"
+// - Address is the host:port to send the metrics to
+// - Prefix is the prefix of the metric names (the measurement name for influx)
+// - Tags are added to every metric. StatsD uses the DogStatsD tags extension
+// - Prefix and the values of the Tags can have {{command}} and {{worker}} placeholders, replaced with the index of the command and worker of each run
+// - BatchSize is the maximum number of lines sent together
+// - FlushInterval is how often a partial batch is sent. 0 only sends full batches
+type SinkOptions struct {
+	Protocol      string
+	Network       string
+	Address       string
+	Prefix        string
+	Tags          map[string]string
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// Parses a sink definition in the `protocol=network://address` format,
+// e.g. "statsd=udp://127.0.0.1:8125", into the options.
+func ParseSink(sink string, options SinkOptions) (SinkOptions, error) {
+	invalid := fmt.Errorf("invalid sink `%s`: expected `protocol=network://address`", sink)
+
+	parts := strings.SplitN(sink, "=", 2)
+	if len(parts) != 2 {
+		return options, invalid
+	}
+
+	target := strings.SplitN(parts[1], "://", 2)
+	if len(target) != 2 || target[1] == "" {
+		return options, invalid
+	}
+
+	options.Protocol = parts[0]
+	options.Network = target[0]
+	options.Address = target[1]
+
+	if options.Protocol != "statsd" && options.Protocol != "graphite" && options.Protocol != "influx" {
+		return options, fmt.Errorf("invalid sink protocol `%s`: use `statsd`, `graphite` or `influx`", options.Protocol)
+	}
+
+	if options.Network != "udp" && options.Network != "tcp" {
+		return options, fmt.Errorf("invalid sink network `%s`: use `udp` or `tcp`", options.Network)
+	}
+
+	return options, nil
+}
+
+// Parses a list of tags in the `key=value,key=value` format.
+func ParseTags(tags string) (map[string]string, error) {
+	result := map[string]string{}
+	if tags == "" {
+		return result, nil
+	}
+
+	for _, tag := range strings.Split(tags, ",") {
+		parts := strings.SplitN(tag, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid tag `%s`: expected `key=value`", tag)
+		}
+		result[parts[0]] = parts[1]
+	}
+
+	return result, nil
+}
+
+// Sink is a runner.Observer that sends the duration and outcome of every run
+// to a StatsD, Graphite or InfluxDB server, in batches. The batches are sent in
+// the background, so a slow server doesn't delay the runs, and are dropped if
+// it falls too far behind.
+type Sink struct {
+	mutex   sync.Mutex
+	options SinkOptions
+	tags    []string
+	conn    net.Conn
+	lines   []string
+	dropped int
+	err     error
+
+	batches chan []string
+	stop    chan bool
+	done    chan bool
+}
+
+// Creates a new instance of the Sink, connected to the options' address.
+// It must be closed to send the last batch.
+func NewSink(options SinkOptions) (*Sink, error) {
+	if options.BatchSize < 1 {
+		return nil, errors.New("batch size must be at least 1")
+	}
+
+	conn, err := net.Dial(options.Network, options.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sink{
+		options: options,
+		conn:    conn,
+		batches: make(chan []string, sinkQueueSize),
+		stop:    make(chan bool),
+		done:    make(chan bool),
+	}
+
+	for key := range options.Tags {
+		s.tags = append(s.tags, key)
+	}
+	sort.Strings(s.tags)
+
+	go s.send()
+	return s, nil
+}
+
+// Observe formats the run and queues the batch to be sent once it's full. If
+// the queue is full too, the batch is dropped rather than delaying the run.
+func (s *Sink) Observe(command string, stats runner.RunStats) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lines = append(s.lines, s.format(command, stats)...)
+	for len(s.lines) >= s.options.BatchSize {
+		batch := s.lines[:s.options.BatchSize]
+		s.lines = s.lines[s.options.BatchSize:]
+
+		select {
+		case s.batches <- batch:
+		default:
+			s.dropped += len(batch)
+		}
+	}
+}
+
+// Close sends the remaining batches and closes the connection.
+// It returns an error if any line was dropped, or else the first error that
+// happened while sending the metrics.
+func (s *Sink) Close() error {
+	close(s.stop)
+	<-s.done
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.conn.Close(); err != nil && s.err == nil {
+		s.err = err
+	}
+
+	if s.dropped > 0 {
+		return fmt.Errorf("dropped %d lines, as the server fell behind", s.dropped)
+	}
+
+	return s.err
+}
+
+// send writes the queued batches, and the partial batch every FlushInterval,
+// until the sink is closed. It then writes whatever is left.
+func (s *Sink) send() {
+	defer close(s.done)
+
+	var flush <-chan time.Time
+	if s.options.FlushInterval > 0 {
+		ticker := time.NewTicker(s.options.FlushInterval)
+		defer ticker.Stop()
+		flush = ticker.C
+	}
+
+	for {
+		select {
+		case batch := <-s.batches:
+			s.write(batch)
+		case <-flush:
+			s.write(s.partial())
+		case <-s.stop:
+			for {
+				select {
+				case batch := <-s.batches:
+					s.write(batch)
+				default:
+					s.write(s.partial())
+					return
+				}
+			}
+		}
+	}
+}
+
+// partial takes the lines that don't fill a batch yet.
+func (s *Sink) partial() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lines := s.lines
+	s.lines = nil
+	return lines
+}
+
+// write sends the lines in a single write, so each UDP datagram stays small.
+func (s *Sink) write(lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+
+	_, err := s.conn.Write([]byte(strings.Join(lines, "\n") + "\n"))
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+// expand replaces the placeholders of the prefix or a tag value with the index
+// of the command and worker of the run.
+func expand(value string, stats runner.RunStats) string {
+	return strings.NewReplacer(
+		"{{command}}", strconv.Itoa(stats.Command),
+		"{{worker}}", strconv.Itoa(stats.Worker),
+	).Replace(value)
+}
+
+// format returns the lines of the run in the sink's protocol.
+func (s *Sink) format(command string, stats runner.RunStats) []string {
+	finished := stats.StartTime.Add(stats.Duration)
+	prefix := expand(s.options.Prefix, stats)
+	name := prefix + "." + unsafeNameChars.ReplaceAllString(command, "_")
+
+	values := make([]string, len(s.tags))
+	for i, key := range s.tags {
+		values[i] = expand(s.options.Tags[key], stats)
+	}
+
+	switch s.options.Protocol {
+	case "statsd":
+		tags := ""
+		if len(s.tags) > 0 {
+			pairs := make([]string, len(s.tags))
+			for i, key := range s.tags {
+				pairs[i] = key + ":" + values[i]
+			}
+			tags = "|#" + strings.Join(pairs, ",")
+		}
+
+		lines := []string{
+			fmt.Sprintf("%s.duration:%g|ms%s", name, float64(stats.Duration)/float64(time.Millisecond), tags),
+			fmt.Sprintf("%s.runs:1|c%s", name, tags),
+		}
+		if stats.Failed {
+			lines = append(lines, fmt.Sprintf("%s.errors:1|c%s", name, tags))
+		}
+		return lines
+
+	case "graphite":
+		tags := ""
+		for i, key := range s.tags {
+			tags += ";" + key + "=" + values[i]
+		}
+
+		failed := 0
+		if stats.Failed {
+			failed = 1
+		}
+
+		return []string{
+			fmt.Sprintf("%s.duration%s %g %d", name, tags, stats.Duration.Seconds(), finished.Unix()),
+			fmt.Sprintf("%s.failed%s %d %d", name, tags, failed, finished.Unix()),
+		}
+
+	default:
+		tags := ",command=" + escapeInflux(command)
+		for i, key := range s.tags {
+			tags += "," + escapeInflux(key) + "=" + escapeInflux(values[i])
+		}
+
+		return []string{
+			fmt.Sprintf("%s%s duration=%di,failed=%t,exit_code=%di %d",
+				escapeInflux(prefix), tags, stats.Duration.Nanoseconds(), stats.Failed, stats.ExitCode, finished.UnixNano()),
+		}
+	}
+}
+
+// escapeInflux escapes a measurement, tag key or tag value of the line protocol.
+func escapeInflux(value string) string {
+	return strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`).Replace(value)
+}
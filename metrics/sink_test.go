@@ -0,0 +1,261 @@
+package metrics_test
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/metrics"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("ParseSink", func() {
+	It("parses the protocol, network and address", func() {
+		options, err := metrics.ParseSink("statsd=udp://127.0.0.1:8125", metrics.SinkOptions{Prefix: "bender"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(options).To(Equal(metrics.SinkOptions{
+			Protocol: "statsd",
+			Network:  "udp",
+			Address:  "127.0.0.1:8125",
+			Prefix:   "bender",
+		}))
+	})
+
+	It("fails when the definition is malformed", func() {
+		_, err := metrics.ParseSink("statsd:8125", metrics.SinkOptions{})
+		Expect(err).To(MatchError("invalid sink `statsd:8125`: expected `protocol=network://address`"))
+	})
+
+	It("fails when the protocol is unknown", func() {
+		_, err := metrics.ParseSink("datadog=udp://127.0.0.1:8125", metrics.SinkOptions{})
+		Expect(err).To(MatchError("invalid sink protocol `datadog`: use `statsd`, `graphite` or `influx`"))
+	})
+
+	It("fails when the network is unknown", func() {
+		_, err := metrics.ParseSink("influx=http://127.0.0.1:8086", metrics.SinkOptions{})
+		Expect(err).To(MatchError("invalid sink network `http`: use `udp` or `tcp`"))
+	})
+})
+
+var _ = Describe("ParseTags", func() {
+	It("parses the tags", func() {
+		tags, err := metrics.ParseTags("env=ci,team=core")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tags).To(Equal(map[string]string{"env": "ci", "team": "core"}))
+	})
+
+	It("fails when a tag has no value", func() {
+		_, err := metrics.ParseTags("env")
+		Expect(err).To(MatchError("invalid tag `env`: expected `key=value`"))
+	})
+})
+
+var _ = Describe("Sink", func() {
+	var (
+		options  metrics.SinkOptions
+		finished time.Time
+		runs     []runner.RunStats
+	)
+
+	BeforeEach(func() {
+		finished = time.Unix(1500000000, 0)
+		runs = []runner.RunStats{
+			{StartTime: finished.Add(-1500 * time.Millisecond), Duration: 1500 * time.Millisecond},
+			{StartTime: finished.Add(-250 * time.Millisecond), Duration: 250 * time.Millisecond, Failed: true, ExitCode: 2},
+		}
+
+		options = metrics.SinkOptions{
+			Prefix:    "bender",
+			Tags:      map[string]string{"env": "ci", "team": "core"},
+			BatchSize: 100,
+		}
+	})
+
+	Context("using udp", func() {
+		var listener net.PacketConn
+
+		BeforeEach(func() {
+			var err error
+			listener, err = net.ListenPacket("udp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+
+			options.Network = "udp"
+			options.Address = listener.LocalAddr().String()
+		})
+
+		AfterEach(func() {
+			listener.Close()
+		})
+
+		receive := func() []string {
+			buffer := make([]byte, 65536)
+			Expect(listener.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+			n, _, err := listener.ReadFrom(buffer)
+			Expect(err).NotTo(HaveOccurred())
+			return strings.Split(strings.TrimSuffix(string(buffer[:n]), "\n"), "\n")
+		}
+
+		send := func() {
+			sink, err := metrics.NewSink(options)
+			Expect(err).NotTo(HaveOccurred())
+			for _, run := range runs {
+				sink.Observe("sleep 1.5", run)
+			}
+			Expect(sink.Close()).To(Succeed())
+		}
+
+		It("sends StatsD timers and counters", func() {
+			options.Protocol = "statsd"
+			send()
+
+			Expect(receive()).To(Equal([]string{
+				"bender.sleep_1_5.duration:1500|ms|#env:ci,team:core",
+				"bender.sleep_1_5.runs:1|c|#env:ci,team:core",
+				"bender.sleep_1_5.duration:250|ms|#env:ci,team:core",
+				"bender.sleep_1_5.runs:1|c|#env:ci,team:core",
+				"bender.sleep_1_5.errors:1|c|#env:ci,team:core",
+			}))
+		})
+
+		It("sends Graphite plaintext metrics", func() {
+			options.Protocol = "graphite"
+			send()
+
+			Expect(receive()).To(Equal([]string{
+				"bender.sleep_1_5.duration;env=ci;team=core 1.5 1500000000",
+				"bender.sleep_1_5.failed;env=ci;team=core 0 1500000000",
+				"bender.sleep_1_5.duration;env=ci;team=core 0.25 1500000000",
+				"bender.sleep_1_5.failed;env=ci;team=core 1 1500000000",
+			}))
+		})
+
+		It("sends InfluxDB line protocol points", func() {
+			options.Protocol = "influx"
+			send()
+
+			Expect(receive()).To(Equal([]string{
+				`bender,command=sleep\ 1.5,env=ci,team=core duration=1500000000i,failed=false,exit_code=0i 1500000000000000000`,
+				`bender,command=sleep\ 1.5,env=ci,team=core duration=250000000i,failed=true,exit_code=2i 1500000000000000000`,
+			}))
+		})
+
+		It("sends the lines in batches", func() {
+			options.Protocol = "influx"
+			options.BatchSize = 1
+			send()
+
+			Expect(receive()).To(HaveLen(1))
+			Expect(receive()).To(HaveLen(1))
+		})
+
+		It("sends partial batches periodically", func() {
+			options.Protocol = "influx"
+			options.FlushInterval = 10 * time.Millisecond
+
+			sink, err := metrics.NewSink(options)
+			Expect(err).NotTo(HaveOccurred())
+			defer sink.Close()
+
+			sink.Observe("sleep 1.5", runs[0])
+			Expect(receive()).To(HaveLen(1))
+		})
+
+		It("replaces the placeholders of the prefix and tags", func() {
+			options.Protocol = "statsd"
+			options.Prefix = "bender.c{{command}}"
+			options.Tags = map[string]string{"worker": "{{worker}}"}
+			runs = []runner.RunStats{{Command: 2, Worker: 3, Duration: time.Millisecond}}
+			send()
+
+			Expect(receive()).To(Equal([]string{
+				"bender.c2.sleep_1_5.duration:1|ms|#worker:3",
+				"bender.c2.sleep_1_5.runs:1|c|#worker:3",
+			}))
+		})
+	})
+
+	Context("using tcp", func() {
+		It("sends the lines over the connection", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer listener.Close()
+
+			received := make(chan []string)
+			go func() {
+				defer GinkgoRecover()
+				conn, err := listener.Accept()
+				Expect(err).NotTo(HaveOccurred())
+				defer conn.Close()
+
+				lines := []string{}
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines = append(lines, scanner.Text())
+				}
+				received <- lines
+			}()
+
+			options.Protocol = "graphite"
+			options.Network = "tcp"
+			options.Address = listener.Addr().String()
+
+			sink, err := metrics.NewSink(options)
+			Expect(err).NotTo(HaveOccurred())
+			sink.Observe("ls", runs[0])
+			Expect(sink.Close()).To(Succeed())
+
+			Eventually(received).Should(Receive(Equal([]string{
+				"bender.ls.duration;env=ci;team=core 1.5 1500000000",
+				"bender.ls.failed;env=ci;team=core 0 1500000000",
+			})))
+		})
+
+		It("drops the batches rather than delaying the runs when the server falls behind", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer listener.Close()
+
+			accepted := make(chan net.Conn, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err == nil {
+					accepted <- conn
+				}
+			}()
+
+			options.Protocol = "influx"
+			options.Network = "tcp"
+			options.Address = listener.Addr().String()
+			options.BatchSize = 1
+
+			sink, err := metrics.NewSink(options)
+			Expect(err).NotTo(HaveOccurred())
+
+			// The server never reads, so the lines fill the socket buffers.
+			observed := make(chan bool)
+			command := strings.Repeat("x", 64*1024)
+			go func() {
+				for i := 0; i < 1000; i++ {
+					sink.Observe(command, runs[0])
+				}
+				close(observed)
+			}()
+			Eventually(observed, 5*time.Second).Should(BeClosed())
+
+			var conn net.Conn
+			Eventually(accepted).Should(Receive(&conn))
+			conn.Close()
+
+			Expect(sink.Close()).To(MatchError(MatchRegexp(`^dropped \d+ lines, as the server fell behind$`)))
+		})
+	})
+
+	It("fails when the batch size is invalid", func() {
+		options.BatchSize = 0
+		_, err := metrics.NewSink(options)
+		Expect(err).To(MatchError("batch size must be at least 1"))
+	})
+})
@@ -0,0 +1,311 @@
+package otlp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tscolari/bender/metrics"
+	"github.com/tscolari/bender/runner"
+)
+
+// Name of the environment variable the W3C trace context is injected into,
+// following the OpenTelemetry environment variables carrier.
+const TraceParentEnv = "TRACEPARENT"
+
+// Defines how the Exporter sends the telemetry
+// - Endpoint is the base URL of the OTLP/HTTP collector, e.g. http://localhost:4318
+// - ServiceName is the `service.name` of the telemetry
+// - Interval is how often the finished spans and the metrics are exported
+// - Commands are the commands given to the runner, in order, which name the spans
+// and the metrics of their runs (see runner.RunStats.Command), rather than the
+// arguments of each run, which change with the placeholders
+type Options struct {
+	Endpoint    string
+	ServiceName string
+	Interval    time.Duration
+	Commands    []string
+}
+
+// Exporter is a runner.Hook that exports a span for every run, under a root span
+// for the whole benchmark, and metrics of the runs to an OTLP/HTTP collector.
+// The trace context of each run is injected into the command's environment
+// (see TraceParentEnv), so instrumented commands can join the trace.
+type Exporter struct {
+	mutex    sync.Mutex
+	options  Options
+	client   *http.Client
+	resource resource
+
+	traceID    string
+	rootSpanID string
+	start      time.Time
+
+	running  map[*exec.Cmd]string
+	spans    []span
+	commands map[string]*commandMetrics
+	err      error
+
+	stop chan bool
+	done chan bool
+}
+
+type commandMetrics struct {
+	runs    int
+	errors  int
+	sum     float64
+	buckets []int
+}
+
+// Creates a new instance of the Exporter, starting the root span.
+// It must be closed to end the root span and export the remaining telemetry.
+func NewExporter(options Options) (*Exporter, error) {
+	if options.Endpoint == "" {
+		return nil, errors.New("no OTLP endpoint given")
+	}
+
+	if options.Interval <= 0 {
+		return nil, errors.New("export interval must be positive")
+	}
+
+	e := &Exporter{
+		options:    options,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		resource:   resource{Attributes: []keyValue{stringAttribute("service.name", options.ServiceName)}},
+		traceID:    newID(16),
+		rootSpanID: newID(8),
+		start:      time.Now(),
+		running:    map[*exec.Cmd]string{},
+		commands:   map[string]*commandMetrics{},
+		stop:       make(chan bool),
+		done:       make(chan bool),
+	}
+
+	go e.exportPeriodically()
+	return e, nil
+}
+
+// Before injects the trace context of the run's span into the command's environment.
+func (e *Exporter) Before(cmd *exec.Cmd, _ *runner.RunStats) {
+	spanID := newID(8)
+
+	e.mutex.Lock()
+	e.running[cmd] = spanID
+	e.mutex.Unlock()
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=00-%s-%s-01", TraceParentEnv, e.traceID, spanID))
+}
+
+// After records the span and the metrics of the run.
+func (e *Exporter) After(cmd *exec.Cmd, stats *runner.RunStats) {
+	command := e.command(cmd, stats)
+
+	s := span{
+		TraceID:           e.traceID,
+		ParentSpanID:      e.rootSpanID,
+		Name:              command,
+		Kind:              spanKindClient,
+		StartTimeUnixNano: unixNano(stats.StartTime),
+		EndTimeUnixNano:   unixNano(stats.StartTime.Add(stats.Duration)),
+		Attributes: []keyValue{
+			stringAttribute("bender.command", command),
+			stringAttribute("process.command_line", strings.Join(cmd.Args, " ")),
+			intAttribute("bender.worker", stats.Worker),
+			intAttribute("bender.iteration", stats.Iteration),
+			intAttribute("process.exit.code", stats.ExitCode),
+		},
+	}
+	if stats.Row > 0 {
+		s.Attributes = append(s.Attributes, intAttribute("bender.row", stats.Row))
+	}
+	if stats.Failed {
+		s.Status.Code = statusCodeError
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	s.SpanID = e.running[cmd]
+	delete(e.running, cmd)
+	e.spans = append(e.spans, s)
+
+	m, ok := e.commands[command]
+	if !ok {
+		m = &commandMetrics{buckets: make([]int, len(metrics.DefaultBuckets)+1)}
+		e.commands[command] = m
+	}
+
+	m.runs++
+	if stats.Failed {
+		m.errors++
+	}
+
+	seconds := stats.Duration.Seconds()
+	m.sum += seconds
+	bucket := len(metrics.DefaultBuckets)
+	for i, bound := range metrics.DefaultBuckets {
+		if seconds <= bound {
+			bucket = i
+			break
+		}
+	}
+	m.buckets[bucket]++
+}
+
+// command returns the command given to the runner for the run, or the program
+// run when the commands weren't given.
+func (e *Exporter) command(cmd *exec.Cmd, stats *runner.RunStats) string {
+	if stats.Command > 0 && stats.Command <= len(e.options.Commands) {
+		return e.options.Commands[stats.Command-1]
+	}
+	return cmd.Args[0]
+}
+
+// Close ends the root span and exports the remaining spans and the final metrics.
+// It returns the first error that happened while exporting.
+func (e *Exporter) Close() error {
+	close(e.stop)
+	<-e.done
+
+	e.mutex.Lock()
+	e.spans = append(e.spans, span{
+		TraceID:           e.traceID,
+		SpanID:            e.rootSpanID,
+		Name:              "bender",
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: unixNano(e.start),
+		EndTimeUnixNano:   unixNano(time.Now()),
+	})
+	e.mutex.Unlock()
+
+	e.export()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.err
+}
+
+func (e *Exporter) exportPeriodically() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			e.export()
+		}
+	}
+}
+
+// export sends the finished spans and the current metrics. The requests are
+// built while holding the mutex, but sent without it, so runs aren't delayed.
+func (e *Exporter) export() {
+	e.mutex.Lock()
+	spans := e.spans
+	e.spans = nil
+	pending := e.metricsRequest(time.Now())
+	e.mutex.Unlock()
+
+	if len(spans) > 0 {
+		e.setErr(post(e.client, e.options.Endpoint+"/v1/traces", tracesRequest{
+			ResourceSpans: []resourceSpans{{
+				Resource:   e.resource,
+				ScopeSpans: []scopeSpans{{Scope: scope{Name: "bender"}, Spans: spans}},
+			}},
+		}))
+	}
+
+	if len(pending.ResourceMetrics) > 0 {
+		e.setErr(post(e.client, e.options.Endpoint+"/v1/metrics", pending))
+	}
+}
+
+func (e *Exporter) setErr(err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+}
+
+// metricsRequest builds the cumulative metrics of every command.
+// It must be called with the mutex held.
+func (e *Exporter) metricsRequest(now time.Time) metricsRequest {
+	if len(e.commands) == 0 {
+		return metricsRequest{}
+	}
+
+	runs := &sum{AggregationTemporality: temporalityCumulative, IsMonotonic: true}
+	failures := &sum{AggregationTemporality: temporalityCumulative, IsMonotonic: true}
+	durations := &histogram{AggregationTemporality: temporalityCumulative}
+
+	for command, m := range e.commands {
+		attributes := []keyValue{stringAttribute("bender.command", command)}
+
+		runs.DataPoints = append(runs.DataPoints, numberDataPoint{
+			Attributes:        attributes,
+			StartTimeUnixNano: unixNano(e.start),
+			TimeUnixNano:      unixNano(now),
+			AsInt:             strconv.Itoa(m.runs),
+		})
+
+		failures.DataPoints = append(failures.DataPoints, numberDataPoint{
+			Attributes:        attributes,
+			StartTimeUnixNano: unixNano(e.start),
+			TimeUnixNano:      unixNano(now),
+			AsInt:             strconv.Itoa(m.errors),
+		})
+
+		counts := make([]string, len(m.buckets))
+		for i, count := range m.buckets {
+			counts[i] = strconv.Itoa(count)
+		}
+
+		durations.DataPoints = append(durations.DataPoints, histogramDataPoint{
+			Attributes:        attributes,
+			StartTimeUnixNano: unixNano(e.start),
+			TimeUnixNano:      unixNano(now),
+			Count:             strconv.Itoa(m.runs),
+			Sum:               m.sum,
+			BucketCounts:      counts,
+			ExplicitBounds:    metrics.DefaultBuckets,
+		})
+	}
+
+	return metricsRequest{
+		ResourceMetrics: []resourceMetrics{{
+			Resource: e.resource,
+			ScopeMetrics: []scopeMetrics{{
+				Scope: scope{Name: "bender"},
+				Metrics: []metric{
+					{Name: "bender.runs", Unit: "{run}", Sum: runs},
+					{Name: "bender.errors", Unit: "{run}", Sum: failures},
+					{Name: "bender.run.duration", Unit: "s", Histogram: durations},
+				},
+			}},
+		}},
+	}
+}
+
+// newID returns a random ID of the given number of bytes, hex encoded.
+func newID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,235 @@
+package otlp_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/otlp"
+	"github.com/tscolari/bender/runner"
+)
+
+type collector struct {
+	mutex   sync.Mutex
+	traces  []map[string]interface{}
+	metrics []map[string]interface{}
+	status  int
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&request)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch r.URL.Path {
+	case "/v1/traces":
+		c.traces = append(c.traces, request)
+	case "/v1/metrics":
+		c.metrics = append(c.metrics, request)
+	}
+	w.WriteHeader(c.status)
+}
+
+// spans returns all the spans received, by name.
+func (c *collector) spans() map[string]map[string]interface{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	spans := map[string]map[string]interface{}{}
+	for _, request := range c.traces {
+		for _, rs := range request["resourceSpans"].([]interface{}) {
+			for _, ss := range rs.(map[string]interface{})["scopeSpans"].([]interface{}) {
+				for _, s := range ss.(map[string]interface{})["spans"].([]interface{}) {
+					span := s.(map[string]interface{})
+					spans[span["name"].(string)] = span
+				}
+			}
+		}
+	}
+	return spans
+}
+
+func attribute(span map[string]interface{}, key string) interface{} {
+	for _, a := range span["attributes"].([]interface{}) {
+		attribute := a.(map[string]interface{})
+		if attribute["key"] == key {
+			for _, value := range attribute["value"].(map[string]interface{}) {
+				return value
+			}
+		}
+	}
+	return nil
+}
+
+var _ = Describe("Exporter", func() {
+	var (
+		server    *httptest.Server
+		received  *collector
+		exporter  *otlp.Exporter
+		start     time.Time
+		failedRun runner.RunStats
+	)
+
+	BeforeEach(func() {
+		received = &collector{status: http.StatusOK}
+		server = httptest.NewServer(received)
+
+		var err error
+		exporter, err = otlp.NewExporter(otlp.Options{
+			Endpoint:    server.URL,
+			ServiceName: "bender-test",
+			Interval:    time.Hour,
+			Commands:    []string{"ls {{flag}}"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		start = time.Now()
+		failedRun = runner.RunStats{
+			Command:   1,
+			Worker:    2,
+			Iteration: 3,
+			StartTime: start,
+			Duration:  250 * time.Millisecond,
+			Failed:    true,
+			ExitCode:  4,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("injects the trace context into the command environment", func() {
+		cmd := exec.Command("ls", "-la")
+		exporter.Before(cmd, &runner.RunStats{})
+
+		traceparent := cmd.Env[len(cmd.Env)-1]
+		Expect(traceparent).To(MatchRegexp(`^TRACEPARENT=00-[0-9a-f]{32}-[0-9a-f]{16}-01$`))
+		Expect(len(cmd.Env)).To(BeNumerically(">", 1))
+	})
+
+	It("exports a span for each run under the root span", func() {
+		cmd := exec.Command("ls", "-la")
+		exporter.Before(cmd, &failedRun)
+		exporter.After(cmd, &failedRun)
+		Expect(exporter.Close()).To(Succeed())
+
+		spans := received.spans()
+		Expect(spans).To(HaveLen(2))
+
+		root := spans["bender"]
+		run := spans["ls {{flag}}"]
+		Expect(run["traceId"]).To(Equal(root["traceId"]))
+		Expect(run["parentSpanId"]).To(Equal(root["spanId"]))
+		Expect(root).NotTo(HaveKey("parentSpanId"))
+
+		traceparent := cmd.Env[len(cmd.Env)-1]
+		Expect(traceparent).To(Equal("TRACEPARENT=00-" + run["traceId"].(string) + "-" + run["spanId"].(string) + "-01"))
+	})
+
+	It("includes the command, arguments, worker and exit code of each run", func() {
+		failedRun.Row = 5
+		cmd := exec.Command("ls", "-la")
+		exporter.Before(cmd, &failedRun)
+		exporter.After(cmd, &failedRun)
+		Expect(exporter.Close()).To(Succeed())
+
+		run := received.spans()["ls {{flag}}"]
+		Expect(attribute(run, "bender.command")).To(Equal("ls {{flag}}"))
+		Expect(attribute(run, "process.command_line")).To(Equal("ls -la"))
+		Expect(attribute(run, "bender.row")).To(Equal("5"))
+		Expect(attribute(run, "bender.worker")).To(Equal("2"))
+		Expect(attribute(run, "bender.iteration")).To(Equal("3"))
+		Expect(attribute(run, "process.exit.code")).To(Equal("4"))
+		Expect(run["status"]).To(Equal(map[string]interface{}{"code": 2.0}))
+		Expect(run["startTimeUnixNano"]).To(Equal(strconv.FormatInt(start.UnixNano(), 10)))
+		Expect(run["endTimeUnixNano"]).To(Equal(strconv.FormatInt(start.Add(250*time.Millisecond).UnixNano(), 10)))
+	})
+
+	It("exports the metrics of the runs", func() {
+		cmd := exec.Command("ls")
+		exporter.Before(cmd, &failedRun)
+		exporter.After(cmd, &failedRun)
+		Expect(exporter.Close()).To(Succeed())
+
+		Expect(received.metrics).To(HaveLen(1))
+		encoded, err := json.Marshal(received.metrics[0])
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(string(encoded)).To(ContainSubstring(`"stringValue":"ls {{flag}}"`))
+		Expect(string(encoded)).To(ContainSubstring(`"name":"bender.runs"`))
+		Expect(string(encoded)).To(ContainSubstring(`"name":"bender.errors"`))
+		Expect(string(encoded)).To(ContainSubstring(`"name":"bender.run.duration"`))
+		Expect(string(encoded)).To(ContainSubstring(`"bucketCounts":["0","0","0","0","0","1","0","0","0","0","0","0"]`))
+		Expect(string(encoded)).To(ContainSubstring(`"stringValue":"bender-test"`))
+	})
+
+	It("exports periodically", func() {
+		var err error
+		exporter, err = otlp.NewExporter(otlp.Options{Endpoint: server.URL, Interval: 10 * time.Millisecond})
+		Expect(err).NotTo(HaveOccurred())
+		defer exporter.Close()
+
+		cmd := exec.Command("ls")
+		exporter.Before(cmd, &failedRun)
+		exporter.After(cmd, &failedRun)
+
+		Eventually(received.spans).Should(HaveKey("ls"))
+	})
+
+	It("keys the spans and metrics on the commands rather than the arguments of each run", func() {
+		for _, flag := range []string{"-a", "-l", "-la"} {
+			cmd := exec.Command("ls", flag)
+			exporter.Before(cmd, &failedRun)
+			exporter.After(cmd, &failedRun)
+		}
+		Expect(exporter.Close()).To(Succeed())
+
+		Expect(received.spans()).To(HaveLen(2))
+		encoded, err := json.Marshal(received.metrics[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strings.Count(string(encoded), `"key":"bender.command"`)).To(Equal(3))
+		Expect(string(encoded)).To(ContainSubstring(`"asInt":"3"`))
+	})
+
+	Context("when the commands aren't given", func() {
+		It("names the spans after the program", func() {
+			var err error
+			exporter, err = otlp.NewExporter(otlp.Options{Endpoint: server.URL, Interval: time.Hour})
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := exec.Command("ls", "-la")
+			exporter.Before(cmd, &failedRun)
+			exporter.After(cmd, &failedRun)
+			Expect(exporter.Close()).To(Succeed())
+
+			Expect(received.spans()).To(HaveKey("ls"))
+		})
+	})
+
+	Context("when the collector fails", func() {
+		BeforeEach(func() {
+			received.status = http.StatusInternalServerError
+		})
+
+		It("returns the error when closed", func() {
+			Expect(exporter.Close()).To(MatchError(ContainSubstring("responded with status 500")))
+		})
+	})
+
+	Context("when no endpoint is given", func() {
+		It("fails", func() {
+			_, err := otlp.NewExporter(otlp.Options{Interval: time.Second})
+			Expect(err).To(MatchError("no OTLP endpoint given"))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package otlp_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestOTLP(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "OTLP Suite")
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tscolari/bender/report"
+	"github.com/tscolari/bender/runner"
+	"github.com/urfave/cli"
+)
+
+func reportCommand() cli.Command {
+	return cli.Command{
+		Name:      "report",
+		Usage:     "generate a report from a summary",
+		ArgsUsage: "SUMMARY_FILE",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "output, o",
+				Usage: "write the report to `FILE` instead of stdout",
+			},
+			cli.StringFlag{
+				Name:  "format",
+				Value: "html",
+				Usage: "report format: html or markdown",
+			},
+			cli.StringFlag{
+				Name:  "baseline",
+				Usage: "compare the results with the summary in `FILE`, when using the markdown format",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 1 {
+				return errors.New("Missing the summary file argument")
+			}
+
+			format := c.String("format")
+			if format != "html" && format != "markdown" {
+				return fmt.Errorf("invalid format `%s`: use `html` or `markdown`", format)
+			}
+
+			if c.IsSet("baseline") && format != "markdown" {
+				return errors.New("can't use `--baseline` without `--format markdown`")
+			}
+
+			summary, err := readSummary(c.Args().First())
+			if err != nil {
+				return fmt.Errorf("Failed to read summary: %s", err.Error())
+			}
+
+			baseline, err := readBaseline(c.String("baseline"))
+			if err != nil {
+				return fmt.Errorf("Failed to read baseline: %s", err.Error())
+			}
+
+			write := func(w io.Writer) error {
+				if format == "markdown" {
+					return report.WriteMarkdown(w, summary, baseline)
+				}
+				return report.WriteHTML(w, summary)
+			}
+
+			if c.String("output") != "" {
+				err = writeFile(c.String("output"), write)
+			} else {
+				err = write(os.Stdout)
+			}
+
+			if err != nil {
+				return fmt.Errorf("Failed to write report: %s", err.Error())
+			}
+
+			return nil
+		},
+	}
+}
+
+func readSummary(path string) (runner.Summary, error) {
+	var summary runner.Summary
+
+	file, err := os.Open(path)
+	if err != nil {
+		return summary, err
+	}
+	defer file.Close()
+
+	err = json.NewDecoder(file).Decode(&summary)
+	return summary, err
+}
+
+// readBaseline reads the summary to compare the results with.
+// Returns nil if no path is given.
+func readBaseline(path string) (*runner.Summary, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	baseline, err := readSummary(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &baseline, nil
+}
@@ -0,0 +1,278 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/tscolari/bender/runner"
+)
+
+// Number of bins of the latency histograms.
+const histogramBins = 20
+
+// Maximum number of points of each percentile curve.
+const curvePoints = 200
+
+type htmlCommand struct {
+	Index int
+	runner.Command
+	Values    []string
+	Errors    int
+	Histogram template.HTML
+}
+
+type configEntry struct {
+	Name  string
+	Value string
+}
+
+type htmlReport struct {
+	Summary     runner.Summary
+	Config      []configEntry
+	Commands    []htmlCommand
+	Percentiles template.HTML
+	Throughput  template.HTML
+	Usage       usage
+	System      []template.HTML
+}
+
+// WriteHTML writes a self-contained HTML report of the summary, with the
+// statistics of each command (with the values of its parameters), their latency
+// histograms and percentile curves, the throughput over time, the usage of the
+// host (if it was sampled) and the configuration of the run.
+// All the charts are inline SVG, so the report works without network access.
+func WriteHTML(w io.Writer, summary runner.Summary) error {
+	durations := map[int][]time.Duration{}
+	for _, runStats := range summary.EachRun {
+		durations[runStats.Command] = append(durations[runStats.Command], runStats.Duration)
+	}
+	errors := commandErrors(summary)
+
+	report := htmlReport{Summary: summary}
+
+	for name, value := range summary.Config {
+		report.Config = append(report.Config, configEntry{Name: name, Value: value})
+	}
+	sort.Slice(report.Config, func(i, j int) bool { return report.Config[i].Name < report.Config[j].Name })
+
+	curves := []series{}
+	for i := 1; i <= len(summary.Commands); i++ {
+		sorted := durations[i]
+		sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+
+		cmd := htmlCommand{
+			Index:   i,
+			Command: summary.Commands[i],
+			Errors:  errors[i],
+		}
+		for _, name := range summary.Parameters {
+			cmd.Values = append(cmd.Values, paramValue(cmd.Command, name))
+		}
+
+		if len(sorted) > 0 {
+			cmd.Histogram = histogram(bin(sorted), sorted[0], sorted[len(sorted)-1])
+			curves = append(curves, percentileCurve(fmt.Sprintf("%d: %s", i, cmd.Exec), sorted))
+		}
+
+		report.Commands = append(report.Commands, cmd)
+	}
+
+	report.Percentiles = lineChart(curves, func(x float64) string { return fmt.Sprintf("p%.0f", x) }, formatDuration)
+
+	throughput, err := throughputSeries(summary)
+	if err != nil {
+		return err
+	}
+	report.Throughput = lineChart(throughput, func(x float64) string { return formatDuration(x * float64(time.Second)) }, func(y float64) string { return fmt.Sprintf("%.1f/s", y) })
+
+	if len(summary.System) > 0 {
+		report.Usage = systemUsage(summary.System)
+		report.System = systemCharts(summary)
+	}
+
+	return htmlTemplate.Execute(w, report)
+}
+
+// paramValue returns the value of the parameter of the matrix in the command,
+// or - if the command doesn't use it.
+func paramValue(cmd runner.Command, name string) string {
+	if value, ok := cmd.Params[name]; ok {
+		return value
+	}
+	return "-"
+}
+
+// commandErrors returns the number of failed runs of each command.
+func commandErrors(summary runner.Summary) map[int]int {
+	errors := map[int]int{}
+	for _, runStats := range summary.EachRun {
+		if runStats.Failed {
+			errors[runStats.Command]++
+		}
+	}
+
+	return errors
+}
+
+// bin counts the sorted durations within each of the histogram bins.
+func bin(sorted []time.Duration) []int {
+	bins := make([]int, histogramBins)
+	from, to := sorted[0], sorted[len(sorted)-1]
+
+	for _, d := range sorted {
+		i := 0
+		if to > from {
+			i = int(float64(d-from) / float64(to-from) * histogramBins)
+		}
+		if i == histogramBins {
+			i--
+		}
+		bins[i]++
+	}
+
+	return bins
+}
+
+// percentileCurve returns the duration at each percentile, using at most
+// curvePoints of the sorted durations.
+func percentileCurve(name string, sorted []time.Duration) series {
+	curve := series{Name: name}
+	if len(sorted) == 1 {
+		curve.Points = []point{{X: 0, Y: float64(sorted[0])}, {X: 100, Y: float64(sorted[0])}}
+		return curve
+	}
+
+	step := 1
+	if len(sorted) > curvePoints {
+		step = len(sorted) / curvePoints
+	}
+
+	last := len(sorted) - 1
+	for i := 0; i < last; i += step {
+		curve.Points = append(curve.Points, point{X: float64(i) / float64(last) * 100, Y: float64(sorted[i])})
+	}
+	curve.Points = append(curve.Points, point{X: 100, Y: float64(sorted[last])})
+
+	return curve
+}
+
+// throughputSeries returns the runs and errors per second over time, taken from
+// the summary's time series. If the summary has no time series one is built.
+func throughputSeries(summary runner.Summary) ([]series, error) {
+	buckets := summary.TimeSeries
+	if len(buckets) < 2 {
+		width := summary.Duration / 50
+		if width < time.Millisecond {
+			width = time.Millisecond
+		}
+
+		if err := runner.BuildTimeSeries(&summary, width); err != nil {
+			return nil, err
+		}
+		buckets = summary.TimeSeries
+	}
+
+	width := summary.Duration
+	if len(buckets) > 1 {
+		width = buckets[1].Start - buckets[0].Start
+	}
+	if width <= 0 {
+		return nil, nil
+	}
+
+	runs := series{Name: "runs"}
+	errors := series{Name: "errors"}
+	for _, bucket := range buckets {
+		x := bucket.Start.Seconds()
+		runs.Points = append(runs.Points, point{X: x, Y: float64(bucket.Completed) / width.Seconds()})
+		errors.Points = append(errors.Points, point{X: x, Y: float64(bucket.Errors) / width.Seconds()})
+	}
+
+	return []series{runs, errors}, nil
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"duration": func(d time.Duration) string { return d.Round(time.Microsecond).String() },
+	"bytes":    formatBytes,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Bender report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child, .text { text-align: left; }
+.warning { color: #b35900; }
+</style>
+</head>
+<body>
+<h1>Bender report</h1>
+<table>
+<tr><th>Start time</th><td>{{.Summary.StartTime.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+<tr><th>Duration</th><td>{{duration .Summary.Duration}}</td></tr>
+<tr><th>Successes</th><td>{{.Summary.SuccessCounter}}</td></tr>
+<tr><th>Errors</th><td>{{.Summary.ErrorCounter}}</td></tr>
+{{- if .Summary.Dropped}}
+<tr><th>Dropped</th><td>{{.Summary.Dropped}}</td></tr>
+{{- end}}
+</table>
+{{- if .Config}}
+<h2>Configuration</h2>
+<table>
+{{- range .Config}}
+<tr><th>{{.Name}}</th><td class="text">{{.Value}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Summary.Warnings}}
+<h2>Warnings</h2>
+<ul>
+{{- range .Summary.Warnings}}
+<li class="warning">{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+<h2>Commands</h2>
+<table>
+<tr><th>#</th><th>Command</th>{{range .Summary.Parameters}}<th>{{.}}</th>{{end}}<th>Runs</th><th>Errors</th><th>Mean</th><th>Mean CI</th><th>Median</th><th>Median CI</th><th>P90</th><th>P95</th><th>P99</th><th>Min</th><th>Max</th><th>Std dev</th><th>Outliers</th></tr>
+{{- range .Commands}}
+<tr><td>{{.Index}}</td><td class="text">{{.Exec}}</td>{{range .Values}}<td class="text">{{.}}</td>{{end}}<td>{{.RunCount}}</td><td>{{.Errors}}</td>
+{{- with .Statistics}}
+<td>{{duration .Mean}}</td><td>{{duration .MeanCI.Lower}} - {{duration .MeanCI.Upper}}</td><td>{{duration .Median}}</td><td>{{duration .MedianCI.Lower}} - {{duration .MedianCI.Upper}}</td><td>{{duration .P90}}</td><td>{{duration .P95}}</td><td>{{duration .P99}}</td><td>{{duration .Min}}</td><td>{{duration .Max}}</td><td>{{duration .StdDev}}</td><td>{{.Outliers}}</td>
+{{- else}}
+<td colspan="11">-</td>
+{{- end}}
+</tr>
+{{- end}}
+</table>
+<h2>Latency histograms</h2>
+{{- range .Commands}}
+{{- if .Histogram}}
+<h3>{{.Index}}: {{.Exec}}</h3>
+{{.Histogram}}
+{{- end}}
+{{- end}}
+<h2>Percentiles</h2>
+{{.Percentiles}}
+<h2>Throughput over time</h2>
+{{.Throughput}}
+{{- if .System}}
+<h2>System usage</h2>
+<table>
+<tr><th>CPU</th><td>mean {{printf "%.1f" .Usage.MeanCPU}}%, max {{printf "%.1f" .Usage.MaxCPU}}%</td></tr>
+<tr><th>IO wait</th><td>mean {{printf "%.1f" .Usage.MeanIOWait}}%, max {{printf "%.1f" .Usage.MaxIOWait}}%</td></tr>
+<tr><th>Memory used</th><td>max {{bytes .Usage.MaxMemoryUsed}}</td></tr>
+<tr><th>Load 1m</th><td>max {{printf "%.2f" .Usage.MaxLoad1}}</td></tr>
+</table>
+{{- range .System}}
+{{.}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
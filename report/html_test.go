@@ -0,0 +1,141 @@
+package report_test
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/report"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("WriteHTML", func() {
+	var (
+		summary runner.Summary
+		output  string
+	)
+
+	BeforeEach(func() {
+		start := time.Now()
+		summary = runner.Summary{
+			Commands: map[int]runner.Command{
+				1: {Exec: "ls", RunCount: 3, Statistics: &runner.Statistics{
+					Mean:     2 * time.Millisecond,
+					P99:      3 * time.Millisecond,
+					MeanCI:   runner.Interval{Lower: time.Millisecond, Upper: 3 * time.Millisecond},
+					MedianCI: runner.Interval{Lower: 1500 * time.Microsecond, Upper: 2500 * time.Microsecond},
+				}},
+				2: {Exec: "echo <hi>", RunCount: 1},
+			},
+			StartTime:      start,
+			Duration:       time.Second,
+			SuccessCounter: 3,
+			ErrorCounter:   1,
+			Warnings:       []string{"command 1 (ls): 1 outlier(s) detected"},
+			Config:         map[string]string{"concurrency": "2", "count": "4"},
+			EachRun: []runner.RunStats{
+				{Command: 1, StartTime: start, Duration: time.Millisecond},
+				{Command: 1, StartTime: start, Duration: 2 * time.Millisecond},
+				{Command: 1, StartTime: start, Duration: 3 * time.Millisecond},
+				{Command: 2, StartTime: start, Duration: 500 * time.Millisecond, Failed: true},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		buffer := bytes.NewBuffer([]byte{})
+		Expect(report.WriteHTML(buffer, summary)).To(Succeed())
+		output = buffer.String()
+	})
+
+	It("writes a complete HTML document", func() {
+		Expect(output).To(HavePrefix("<!DOCTYPE html>"))
+		Expect(strings.TrimSpace(output)).To(HaveSuffix("</html>"))
+	})
+
+	It("doesn't depend on any external asset", func() {
+		Expect(output).NotTo(ContainSubstring("<script"))
+		Expect(output).NotTo(ContainSubstring("<link"))
+		Expect(output).NotTo(ContainSubstring("src="))
+	})
+
+	It("includes the statistics of each command", func() {
+		Expect(output).To(ContainSubstring(`<td class="text">ls</td><td>3</td><td>0</td>`))
+		Expect(output).To(ContainSubstring("<td>2ms</td>"))
+		Expect(output).To(ContainSubstring("<td>3ms</td>"))
+	})
+
+	It("includes the confidence intervals of the mean and median", func() {
+		Expect(output).To(ContainSubstring("<th>Mean CI</th><th>Median</th><th>Median CI</th>"))
+		Expect(output).To(ContainSubstring("<td>1ms - 3ms</td>"))
+		Expect(output).To(ContainSubstring("<td>1.5ms - 2.5ms</td>"))
+	})
+
+	It("escapes the commands", func() {
+		Expect(output).To(ContainSubstring("echo &lt;hi&gt;"))
+		Expect(output).NotTo(ContainSubstring("echo <hi>"))
+	})
+
+	It("includes the configuration and warnings", func() {
+		Expect(output).To(ContainSubstring(`<tr><th>concurrency</th><td class="text">2</td></tr>`))
+		Expect(output).To(ContainSubstring(`<tr><th>count</th><td class="text">4</td></tr>`))
+		Expect(output).To(ContainSubstring("command 1 (ls): 1 outlier(s) detected"))
+	})
+
+	It("includes a histogram for each command and the percentile and throughput charts", func() {
+		Expect(strings.Count(output, "<svg")).To(Equal(4))
+		Expect(output).To(ContainSubstring("<h2>Percentiles</h2>"))
+		Expect(output).To(ContainSubstring("<h2>Throughput over time</h2>"))
+	})
+
+	Context("when the commands were expanded from a matrix", func() {
+		BeforeEach(func() {
+			summary.Parameters = []string{"threads"}
+			cmd := summary.Commands[1]
+			cmd.Params = map[string]string{"threads": "4"}
+			summary.Commands[1] = cmd
+		})
+
+		It("includes the values of the parameters as columns", func() {
+			Expect(output).To(ContainSubstring(`<th>Command</th><th>threads</th><th>Runs</th>`))
+			Expect(output).To(ContainSubstring(`<td class="text">ls</td><td class="text">4</td><td>3</td>`))
+			Expect(output).To(ContainSubstring(`<td class="text">echo &lt;hi&gt;</td><td class="text">-</td><td>1</td>`))
+		})
+	})
+
+	It("doesn't include the system usage when it wasn't sampled", func() {
+		Expect(output).NotTo(ContainSubstring("System usage"))
+	})
+
+	Context("when the system usage was sampled", func() {
+		BeforeEach(func() {
+			summary.System = []runner.SystemSample{
+				{Time: summary.StartTime.Add(500 * time.Millisecond), CPU: 20, IOWait: 1, MemoryUsed: 1 << 30, Load1: 0.5},
+				{Time: summary.StartTime.Add(time.Second), CPU: 60, IOWait: 3, MemoryUsed: 3 << 29, Load1: 1.25, DiskWriteRate: 2048},
+			}
+		})
+
+		It("includes the usage and its charts", func() {
+			Expect(output).To(ContainSubstring("<h2>System usage</h2>"))
+			Expect(output).To(ContainSubstring("<tr><th>CPU</th><td>mean 40.0%, max 60.0%</td></tr>"))
+			Expect(output).To(ContainSubstring("<tr><th>IO wait</th><td>mean 2.0%, max 3.0%</td></tr>"))
+			Expect(output).To(ContainSubstring("<tr><th>Memory used</th><td>max 1.5 GiB</td></tr>"))
+			Expect(output).To(ContainSubstring("<tr><th>Load 1m</th><td>max 1.25</td></tr>"))
+			Expect(output).To(ContainSubstring("2.0 KiB/s"))
+			Expect(strings.Count(output, "<svg")).To(Equal(8))
+		})
+	})
+
+	Context("when there are no runs", func() {
+		BeforeEach(func() {
+			summary.EachRun = nil
+			summary.Duration = 0
+		})
+
+		It("still writes the report", func() {
+			Expect(output).To(ContainSubstring("<h2>Commands</h2>"))
+		})
+	})
+})
@@ -0,0 +1,208 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tscolari/bender/runner"
+)
+
+// maxStderrSnippets is how many distinct stderr snippets are added to a failure.
+const maxStderrSnippets = 3
+
+// Defines the thresholds every command of the JUnit report is asserted against
+// - Mean is the maximum mean duration allowed. 0 means no mean threshold
+// - P99 is the maximum p99 duration allowed. 0 means no p99 threshold
+type Thresholds struct {
+	Mean time.Duration
+	P99  time.Duration
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes a JUnit XML report of the summary, so CI systems can show the
+// benchmark like a test run. Each command is a test case, which fails if any of
+// its runs failed or if its statistics are above the thresholds. The failure
+// contains the statistics of the command and snippets of the stderr of the failed
+// runs.
+func WriteJUnit(w io.Writer, summary runner.Summary, thresholds Thresholds) error {
+	suite := junitTestSuite{
+		Name:      "bender",
+		Time:      junitSeconds(summary.Duration),
+		Timestamp: summary.StartTime.UTC().Format("2006-01-02T15:04:05"),
+	}
+
+	for name, value := range summary.Config {
+		suite.Properties = append(suite.Properties, junitProperty{Name: name, Value: value})
+	}
+	sort.Slice(suite.Properties, func(i, j int) bool { return suite.Properties[i].Name < suite.Properties[j].Name })
+
+	durations := map[int]time.Duration{}
+	failedRuns := map[int][]runner.RunStats{}
+	for _, runStats := range summary.EachRun {
+		durations[runStats.Command] += runStats.Duration
+		if runStats.Failed {
+			failedRuns[runStats.Command] = append(failedRuns[runStats.Command], runStats)
+		}
+	}
+
+	for i := 1; i <= len(summary.Commands); i++ {
+		cmd := summary.Commands[i]
+		statistics := junitStatistics(cmd, len(failedRuns[i]))
+
+		testCase := junitTestCase{
+			Name:      cmd.Exec,
+			Classname: "bender",
+			Time:      junitSeconds(durations[i]),
+			SystemOut: statistics,
+		}
+
+		reasons := []string{}
+		if failed := len(failedRuns[i]); failed > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d of %d runs failed", failed, cmd.RunCount))
+		}
+		reasons = append(reasons, thresholds.check(cmd.Statistics)...)
+
+		if len(reasons) > 0 {
+			failureType := "threshold"
+			if len(failedRuns[i]) > 0 {
+				failureType = "failed runs"
+			}
+
+			testCase.Failure = &junitFailure{
+				Message: strings.Join(reasons, "; "),
+				Type:    failureType,
+				Body:    statistics + stderrSnippets(failedRuns[i]),
+			}
+			suite.Failures++
+		}
+
+		suite.TestCases = append(suite.TestCases, testCase)
+		suite.Tests++
+	}
+
+	suites := junitTestSuites{
+		Name:     "bender",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// check returns why the statistics are above the thresholds, if they are.
+func (t Thresholds) check(s *runner.Statistics) []string {
+	if s == nil {
+		return nil
+	}
+
+	reasons := []string{}
+	if t.Mean > 0 && s.Mean > t.Mean {
+		reasons = append(reasons, fmt.Sprintf("mean %s is above the threshold of %s", markdownDuration(s.Mean), t.Mean))
+	}
+
+	if t.P99 > 0 && s.P99 > t.P99 {
+		reasons = append(reasons, fmt.Sprintf("p99 %s is above the threshold of %s", markdownDuration(s.P99), t.P99))
+	}
+
+	return reasons
+}
+
+func junitStatistics(cmd runner.Command, errors int) string {
+	text := fmt.Sprintf("runs: %d, errors: %d\n", cmd.RunCount, errors)
+
+	if s := cmd.Statistics; s != nil {
+		text += fmt.Sprintf("mean: %s, median: %s, p90: %s, p99: %s, min: %s, max: %s, std dev: %s\n",
+			markdownDuration(s.Mean),
+			markdownDuration(s.Median),
+			markdownDuration(s.P90),
+			markdownDuration(s.P99),
+			markdownDuration(s.Min),
+			markdownDuration(s.Max),
+			markdownDuration(s.StdDev),
+		)
+	}
+
+	return text
+}
+
+// stderrSnippets formats the first distinct stderr outputs of the failed runs.
+func stderrSnippets(failedRuns []runner.RunStats) string {
+	text := ""
+	seen := map[string]bool{}
+	for _, runStats := range failedRuns {
+		if runStats.Stderr == "" || seen[runStats.Stderr] {
+			continue
+		}
+
+		if len(seen) == maxStderrSnippets {
+			text += "\n(more stderr output omitted)\n"
+			break
+		}
+		seen[runStats.Stderr] = true
+
+		text += fmt.Sprintf("\nstderr of worker %d, iteration %d (exit code %d):\n%s", runStats.Worker, runStats.Iteration, runStats.ExitCode, runStats.Stderr)
+		if !strings.HasSuffix(runStats.Stderr, "\n") {
+			text += "\n"
+		}
+	}
+
+	return text
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,125 @@
+package report_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/report"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("WriteJUnit", func() {
+	type failure struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr"`
+		Body    string `xml:",chardata"`
+	}
+
+	type testCase struct {
+		Name      string   `xml:"name,attr"`
+		Time      string   `xml:"time,attr"`
+		Failure   *failure `xml:"failure"`
+		SystemOut string   `xml:"system-out"`
+	}
+
+	type testSuites struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+		Suites   []struct {
+			Properties []struct {
+				Name  string `xml:"name,attr"`
+				Value string `xml:"value,attr"`
+			} `xml:"properties>property"`
+			TestCases []testCase `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+
+	var (
+		summary    runner.Summary
+		thresholds report.Thresholds
+		output     string
+		suites     testSuites
+	)
+
+	BeforeEach(func() {
+		summary = runner.Summary{
+			Commands: map[int]runner.Command{
+				1: {Exec: "ls", RunCount: 2, Statistics: &runner.Statistics{Mean: 10 * time.Millisecond, P99: 20 * time.Millisecond}},
+				2: {Exec: "cat a", RunCount: 3, Statistics: &runner.Statistics{Mean: time.Second, P99: 2 * time.Second}},
+			},
+			Duration: 2 * time.Second,
+			Config:   map[string]string{"count": "5"},
+			EachRun: []runner.RunStats{
+				{Command: 1, Duration: 10 * time.Millisecond},
+				{Command: 1, Duration: 10 * time.Millisecond},
+				{Command: 2, Duration: time.Second, Failed: true, Worker: 1, Iteration: 1, ExitCode: 1, Stderr: "cat: a: No such file or directory\n"},
+				{Command: 2, Duration: time.Second, Failed: true, Worker: 1, Iteration: 2, ExitCode: 1, Stderr: "cat: a: No such file or directory\n"},
+				{Command: 2, Duration: time.Second},
+			},
+		}
+		thresholds = report.Thresholds{}
+	})
+
+	JustBeforeEach(func() {
+		buffer := bytes.NewBuffer([]byte{})
+		Expect(report.WriteJUnit(buffer, summary, thresholds)).To(Succeed())
+		output = buffer.String()
+
+		suites = testSuites{}
+		Expect(xml.Unmarshal(buffer.Bytes(), &suites)).To(Succeed())
+	})
+
+	It("writes a test case for each command", func() {
+		Expect(output).To(HavePrefix(xml.Header))
+		Expect(suites.Tests).To(Equal(2))
+		Expect(suites.Suites).To(HaveLen(1))
+
+		testCases := suites.Suites[0].TestCases
+		Expect(testCases).To(HaveLen(2))
+		Expect(testCases[0].Name).To(Equal("ls"))
+		Expect(testCases[0].Time).To(Equal("0.020"))
+		Expect(testCases[0].Failure).To(BeNil())
+		Expect(testCases[0].SystemOut).To(ContainSubstring("runs: 2, errors: 0\nmean: 10ms"))
+		Expect(testCases[1].Name).To(Equal("cat a"))
+		Expect(testCases[1].Time).To(Equal("3.000"))
+	})
+
+	It("adds the config as properties", func() {
+		Expect(suites.Suites[0].Properties).To(HaveLen(1))
+		Expect(suites.Suites[0].Properties[0].Name).To(Equal("count"))
+		Expect(suites.Suites[0].Properties[0].Value).To(Equal("5"))
+	})
+
+	It("fails the commands with failed runs, with the statistics and distinct stderr snippets", func() {
+		Expect(suites.Failures).To(Equal(1))
+
+		failure := suites.Suites[0].TestCases[1].Failure
+		Expect(failure).NotTo(BeNil())
+		Expect(failure.Type).To(Equal("failed runs"))
+		Expect(failure.Message).To(Equal("2 of 3 runs failed"))
+		Expect(failure.Body).To(ContainSubstring("runs: 3, errors: 2\nmean: 1s, median: 0s, p90: 0s, p99: 2s"))
+		Expect(failure.Body).To(ContainSubstring("stderr of worker 1, iteration 1 (exit code 1):\ncat: a: No such file or directory\n"))
+		Expect(failure.Body).NotTo(ContainSubstring("iteration 2"))
+	})
+
+	Context("when thresholds are given", func() {
+		BeforeEach(func() {
+			thresholds = report.Thresholds{Mean: 5 * time.Millisecond, P99: 100 * time.Millisecond}
+		})
+
+		It("fails the commands above them", func() {
+			Expect(suites.Failures).To(Equal(2))
+
+			failure := suites.Suites[0].TestCases[0].Failure
+			Expect(failure).NotTo(BeNil())
+			Expect(failure.Type).To(Equal("threshold"))
+			Expect(failure.Message).To(Equal("mean 10ms is above the threshold of 5ms"))
+
+			failure = suites.Suites[0].TestCases[1].Failure
+			Expect(failure.Message).To(Equal("2 of 3 runs failed; mean 1s is above the threshold of 5ms; p99 2s is above the threshold of 100ms"))
+		})
+	})
+})
@@ -0,0 +1,195 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tscolari/bender/runner"
+)
+
+// Verdicts of the comparison of a command against the baseline.
+const (
+	VerdictFaster    = "faster"
+	VerdictSlower    = "slower"
+	VerdictUnchanged = "no significant change"
+	VerdictNew       = "new"
+	VerdictRemoved   = "removed"
+	VerdictNoData    = "no data"
+)
+
+// WriteMarkdown writes a GitHub flavoured Markdown report of the summary, with a
+// table of the statistics and confidence intervals of each command (with the
+// values of the parameters of the matrix it was expanded from, if any), the
+// usage of the host if it was sampled and the overhead of starting a command if
+// it was calibrated.
+// If a baseline is given, a table comparing each command with the baseline run
+// of the same command is added (see Verdict).
+func WriteMarkdown(w io.Writer, summary runner.Summary, baseline *runner.Summary) error {
+	errors := commandErrors(summary)
+	md := &strings.Builder{}
+
+	fmt.Fprintf(md, "**Duration:** %s | **Successes:** %d | **Errors:** %d\n\n", formatDuration(float64(summary.Duration)), summary.SuccessCounter, summary.ErrorCounter)
+
+	if len(summary.System) > 0 {
+		u := systemUsage(summary.System)
+		fmt.Fprintf(md, "**CPU:** mean %.1f%%, max %.1f%% | **IO wait:** mean %.1f%%, max %.1f%% | **Memory used:** max %s | **Load 1m:** max %.2f\n\n",
+			u.MeanCPU, u.MaxCPU, u.MeanIOWait, u.MaxIOWait, formatBytes(u.MaxMemoryUsed), u.MaxLoad1)
+	}
+
+	if o := summary.Overhead; o != nil {
+		subtracted := "not subtracted from the statistics"
+		if o.Subtracted {
+			subtracted = "subtracted from the statistics"
+		}
+		fmt.Fprintf(md, "**Overhead:** median %s, p99 %s over %d runs of %s, %s\n\n",
+			markdownDuration(o.Statistics.Median), markdownDuration(o.Statistics.P99), o.Runs, markdownCode(o.Command), subtracted)
+	}
+
+	md.WriteString("| # | Command |")
+	for _, name := range summary.Parameters {
+		fmt.Fprintf(md, " %s |", name)
+	}
+	md.WriteString(" Runs | Errors | Mean | Mean CI | Median | Median CI | P90 | P99 | Min | Max | Std dev |\n")
+	md.WriteString("|--:|:--|" + strings.Repeat(":--|", len(summary.Parameters)) + "--:|--:|--:|--:|--:|--:|--:|--:|--:|--:|--:|\n")
+	for i := 1; i <= len(summary.Commands); i++ {
+		cmd := summary.Commands[i]
+		fmt.Fprintf(md, "| %d | %s | ", i, markdownCode(cmd.Exec))
+		for _, name := range summary.Parameters {
+			fmt.Fprintf(md, "%s | ", paramValue(cmd, name))
+		}
+		fmt.Fprintf(md, "%d | %d | ", cmd.RunCount, errors[i])
+
+		if s := cmd.Statistics; s != nil {
+			fmt.Fprintf(md, "%s | %s | %s | %s | %s | %s | %s | %s | %s |\n",
+				markdownDuration(s.Mean),
+				markdownInterval(s.MeanCI),
+				markdownDuration(s.Median),
+				markdownInterval(s.MedianCI),
+				markdownDuration(s.P90),
+				markdownDuration(s.P99),
+				markdownDuration(s.Min),
+				markdownDuration(s.Max),
+				markdownDuration(s.StdDev),
+			)
+		} else {
+			md.WriteString("- | - | - | - | - | - | - | - | - |\n")
+		}
+	}
+
+	if len(summary.Warnings) > 0 {
+		md.WriteString("\n")
+		for _, warning := range summary.Warnings {
+			fmt.Fprintf(md, "> Warning: %s\n", warning)
+		}
+	}
+
+	if baseline != nil {
+		md.WriteString("\n| Command | Baseline mean | Mean | Δ mean | Baseline P99 | P99 | Δ P99 | Verdict |\n")
+		md.WriteString("|:--|--:|--:|--:|--:|--:|--:|:--|\n")
+		for _, row := range compare(summary, *baseline) {
+			fmt.Fprintf(md, "| %s | %s | %s | %s | %s | %s | %s | %s |\n",
+				markdownCode(row.exec),
+				markdownStatistic(row.baseline, func(s *runner.Statistics) time.Duration { return s.Mean }),
+				markdownStatistic(row.current, func(s *runner.Statistics) time.Duration { return s.Mean }),
+				markdownDelta(row.baseline, row.current, func(s *runner.Statistics) time.Duration { return s.Mean }),
+				markdownStatistic(row.baseline, func(s *runner.Statistics) time.Duration { return s.P99 }),
+				markdownStatistic(row.current, func(s *runner.Statistics) time.Duration { return s.P99 }),
+				markdownDelta(row.baseline, row.current, func(s *runner.Statistics) time.Duration { return s.P99 }),
+				row.verdict,
+			)
+		}
+	}
+
+	_, err := io.WriteString(w, md.String())
+	return err
+}
+
+type comparison struct {
+	exec     string
+	baseline *runner.Statistics
+	current  *runner.Statistics
+	verdict  string
+}
+
+// compare matches the commands of both summaries by their command line, in the
+// order of the current summary followed by the ones only in the baseline.
+func compare(summary, baseline runner.Summary) []comparison {
+	baselineStats := map[string]*runner.Statistics{}
+	for i := 1; i <= len(baseline.Commands); i++ {
+		baselineStats[baseline.Commands[i].Exec] = baseline.Commands[i].Statistics
+	}
+
+	rows := []comparison{}
+	seen := map[string]bool{}
+	for i := 1; i <= len(summary.Commands); i++ {
+		cmd := summary.Commands[i]
+		seen[cmd.Exec] = true
+
+		before, ok := baselineStats[cmd.Exec]
+		row := comparison{exec: cmd.Exec, baseline: before, current: cmd.Statistics, verdict: VerdictNew}
+		if ok {
+			row.verdict = Verdict(before, cmd.Statistics)
+		}
+		rows = append(rows, row)
+	}
+
+	for i := 1; i <= len(baseline.Commands); i++ {
+		cmd := baseline.Commands[i]
+		if !seen[cmd.Exec] {
+			rows = append(rows, comparison{exec: cmd.Exec, baseline: cmd.Statistics, verdict: VerdictRemoved})
+		}
+	}
+
+	return rows
+}
+
+// Verdict compares the statistics of a command with its baseline. The command is
+// only considered faster or slower if the confidence intervals of the means don't
+// overlap, otherwise the difference can't be told apart from noise.
+func Verdict(baseline, current *runner.Statistics) string {
+	if baseline == nil || current == nil {
+		return VerdictNoData
+	}
+
+	if current.MeanCI.Upper < baseline.MeanCI.Lower {
+		return VerdictFaster
+	}
+
+	if current.MeanCI.Lower > baseline.MeanCI.Upper {
+		return VerdictSlower
+	}
+
+	return VerdictUnchanged
+}
+
+func markdownStatistic(s *runner.Statistics, value func(*runner.Statistics) time.Duration) string {
+	if s == nil {
+		return "-"
+	}
+	return markdownDuration(value(s))
+}
+
+func markdownDelta(baseline, current *runner.Statistics, value func(*runner.Statistics) time.Duration) string {
+	if baseline == nil || current == nil || value(baseline) == 0 {
+		return "-"
+	}
+
+	delta := float64(value(current)-value(baseline)) / float64(value(baseline)) * 100
+	return fmt.Sprintf("%+.1f%%", delta)
+}
+
+func markdownDuration(d time.Duration) string {
+	return formatDuration(float64(d))
+}
+
+func markdownInterval(i runner.Interval) string {
+	return markdownDuration(i.Lower) + " - " + markdownDuration(i.Upper)
+}
+
+// markdownCode formats the command as inline code, escaping the pipes so they
+// don't break the table.
+func markdownCode(exec string) string {
+	return "`" + strings.Replace(exec, "|", "\\|", -1) + "`"
+}
@@ -0,0 +1,180 @@
+package report_test
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/report"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("WriteMarkdown", func() {
+	var (
+		summary  runner.Summary
+		baseline *runner.Summary
+		output   string
+	)
+
+	statistics := func(mean, lower, upper time.Duration) *runner.Statistics {
+		return &runner.Statistics{
+			Mean:   mean,
+			MeanCI: runner.Interval{Lower: lower, Upper: upper, Confidence: 0.95},
+			P99:    2 * mean,
+		}
+	}
+
+	BeforeEach(func() {
+		summary = runner.Summary{
+			Commands: map[int]runner.Command{
+				1: {Exec: "ls", RunCount: 2, Statistics: statistics(10*time.Millisecond, 9*time.Millisecond, 11*time.Millisecond)},
+				2: {Exec: "cat a | wc", RunCount: 1, Statistics: statistics(time.Second, 900*time.Millisecond, 1100*time.Millisecond)},
+			},
+			Duration:       2 * time.Second,
+			SuccessCounter: 2,
+			ErrorCounter:   1,
+			EachRun: []runner.RunStats{
+				{Command: 1},
+				{Command: 1, Failed: true},
+				{Command: 2},
+			},
+		}
+		baseline = nil
+	})
+
+	JustBeforeEach(func() {
+		buffer := bytes.NewBuffer([]byte{})
+		Expect(report.WriteMarkdown(buffer, summary, baseline)).To(Succeed())
+		output = buffer.String()
+	})
+
+	It("writes a table with the statistics of each command", func() {
+		Expect(output).To(ContainSubstring("| # | Command | Runs | Errors | Mean | Mean CI | Median | Median CI | P90 | P99 | Min | Max | Std dev |\n"))
+		Expect(output).To(ContainSubstring("| 1 | `ls` | 2 | 1 | 10ms | 9ms - 11ms | 0s | 0s - 0s | 0s | 20ms | 0s | 0s | 0s |\n"))
+	})
+
+	It("escapes the pipes in the commands", func() {
+		Expect(output).To(ContainSubstring("| 2 | `cat a \\| wc` | 1 | 0 | 1s |"))
+	})
+
+	It("doesn't include the system usage when it wasn't sampled", func() {
+		Expect(output).NotTo(ContainSubstring("**CPU:**"))
+	})
+
+	Context("when the system usage was sampled", func() {
+		BeforeEach(func() {
+			summary.System = []runner.SystemSample{
+				{CPU: 20, IOWait: 1, MemoryUsed: 512 << 20, Load1: 0.5},
+				{CPU: 60, IOWait: 3, MemoryUsed: 1 << 20, Load1: 1.25},
+			}
+		})
+
+		It("summarizes it", func() {
+			Expect(output).To(ContainSubstring("**CPU:** mean 40.0%, max 60.0% | **IO wait:** mean 2.0%, max 3.0% | **Memory used:** max 512.0 MiB | **Load 1m:** max 1.25\n"))
+		})
+	})
+
+	Context("when the commands were expanded from a matrix", func() {
+		BeforeEach(func() {
+			summary.Parameters = []string{"threads", "algo"}
+			cmd := summary.Commands[1]
+			cmd.Params = map[string]string{"threads": "4", "algo": "a"}
+			summary.Commands[1] = cmd
+		})
+
+		It("includes the values of the parameters as columns", func() {
+			Expect(output).To(ContainSubstring("| # | Command | threads | algo | Runs | Errors | Mean |"))
+			Expect(output).To(ContainSubstring("|--:|:--|:--|:--|--:|--:|--:|--:|--:|--:|--:|--:|--:|--:|--:|\n"))
+			Expect(output).To(ContainSubstring("| 1 | `ls` | 4 | a | 2 | 1 | 10ms |"))
+			Expect(output).To(ContainSubstring("| 2 | `cat a \\| wc` | - | - | 1 | 0 | 1s |"))
+		})
+	})
+
+	It("doesn't include the overhead when it wasn't calibrated", func() {
+		Expect(output).NotTo(ContainSubstring("**Overhead:**"))
+	})
+
+	Context("when the overhead was calibrated", func() {
+		BeforeEach(func() {
+			summary.Overhead = &runner.Overhead{
+				Command:    "true",
+				Runs:       100,
+				Statistics: runner.Statistics{Median: 800 * time.Microsecond, P99: 2 * time.Millisecond},
+			}
+		})
+
+		It("describes it", func() {
+			Expect(output).To(ContainSubstring("**Overhead:** median 800µs, p99 2ms over 100 runs of `true`, not subtracted from the statistics\n"))
+		})
+
+		Context("and subtracted", func() {
+			BeforeEach(func() {
+				summary.Overhead.Subtracted = true
+			})
+
+			It("says so", func() {
+				Expect(output).To(ContainSubstring("over 100 runs of `true`, subtracted from the statistics\n"))
+			})
+		})
+	})
+
+	It("doesn't include a comparison", func() {
+		Expect(output).NotTo(ContainSubstring("Baseline"))
+	})
+
+	Context("when a command has no statistics", func() {
+		BeforeEach(func() {
+			summary.Commands[3] = runner.Command{Exec: "true"}
+		})
+
+		It("leaves its statistics blank", func() {
+			Expect(output).To(ContainSubstring("| 3 | `true` | 0 | 0 | - | - | - | - | - | - | - | - | - |\n"))
+		})
+	})
+
+	Context("when a baseline is given", func() {
+		BeforeEach(func() {
+			baseline = &runner.Summary{
+				Commands: map[int]runner.Command{
+					1: {Exec: "cat a | wc", Statistics: statistics(800*time.Millisecond, 750*time.Millisecond, 850*time.Millisecond)},
+					2: {Exec: "ls", Statistics: statistics(10500*time.Microsecond, 9*time.Millisecond, 12*time.Millisecond)},
+					3: {Exec: "sleep 1", Statistics: statistics(time.Second, time.Second, time.Second)},
+				},
+			}
+		})
+
+		It("compares the commands with the same command line", func() {
+			Expect(output).To(ContainSubstring("| Command | Baseline mean | Mean | Δ mean | Baseline P99 | P99 | Δ P99 | Verdict |\n"))
+			Expect(output).To(ContainSubstring("| `ls` | 10.5ms | 10ms | -4.8% | 21ms | 20ms | -4.8% | no significant change |\n"))
+			Expect(output).To(ContainSubstring("| `cat a \\| wc` | 800ms | 1s | +25.0% | 1.6s | 2s | +25.0% | slower |\n"))
+		})
+
+		It("includes the commands that are only in the baseline", func() {
+			Expect(output).To(ContainSubstring("| `sleep 1` | 1s | - | - | 2s | - | - | removed |\n"))
+		})
+	})
+})
+
+var _ = Describe("Verdict", func() {
+	interval := func(lower, upper time.Duration) *runner.Statistics {
+		return &runner.Statistics{MeanCI: runner.Interval{Lower: lower, Upper: upper}}
+	}
+
+	It("is faster when the intervals don't overlap and the mean went down", func() {
+		Expect(report.Verdict(interval(10, 20), interval(1, 9))).To(Equal(report.VerdictFaster))
+	})
+
+	It("is slower when the intervals don't overlap and the mean went up", func() {
+		Expect(report.Verdict(interval(10, 20), interval(21, 30))).To(Equal(report.VerdictSlower))
+	})
+
+	It("is unchanged when the intervals overlap", func() {
+		Expect(report.Verdict(interval(10, 20), interval(15, 25))).To(Equal(report.VerdictUnchanged))
+	})
+
+	It("has no data when any of the statistics is missing", func() {
+		Expect(report.Verdict(nil, interval(15, 25))).To(Equal(report.VerdictNoData))
+		Expect(report.Verdict(interval(10, 20), nil)).To(Equal(report.VerdictNoData))
+	})
+})
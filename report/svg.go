@@ -0,0 +1,128 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+	"time"
+)
+
+const (
+	chartWidth  = 640
+	chartHeight = 240
+	chartMargin = 50
+)
+
+// Colors of the series, in the order they are drawn.
+var palette = []string{"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f", "#edc948", "#b07aa1", "#ff9da7"}
+
+type point struct {
+	X float64
+	Y float64
+}
+
+type series struct {
+	Name   string
+	Points []point
+}
+
+// lineChart renders the series as an inline SVG line chart. Both axes start at
+// 0 and are scaled to fit every point. The axis labels are formatted with the
+// given functions.
+func lineChart(all []series, formatX, formatY func(float64) string) template.HTML {
+	var maxX, maxY float64
+	for _, s := range all {
+		for _, p := range s.Points {
+			if p.X > maxX {
+				maxX = p.X
+			}
+			if p.Y > maxY {
+				maxY = p.Y
+			}
+		}
+	}
+
+	scale := func(p point) (float64, float64) {
+		x, y := float64(chartMargin), float64(chartHeight-chartMargin)
+		if maxX > 0 {
+			x += p.X / maxX * (chartWidth - 2*chartMargin)
+		}
+		if maxY > 0 {
+			y -= p.Y / maxY * (chartHeight - 2*chartMargin)
+		}
+		return x, y
+	}
+
+	svg := &strings.Builder{}
+	openChart(svg, formatX(0), formatX(maxX), formatY(0), formatY(maxY))
+
+	for i, s := range all {
+		color := palette[i%len(palette)]
+
+		coordinates := make([]string, len(s.Points))
+		for j, p := range s.Points {
+			x, y := scale(p)
+			coordinates[j] = fmt.Sprintf("%.1f,%.1f", x, y)
+		}
+
+		fmt.Fprintf(svg, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, color, strings.Join(coordinates, " "))
+		fmt.Fprintf(svg, `<text x="%d" y="%d" fill="%s">%s</text>`, chartMargin+(i%4)*140, 15+(i/4)*15, color, html.EscapeString(s.Name))
+	}
+
+	svg.WriteString("</svg>")
+	return template.HTML(svg.String())
+}
+
+// histogram renders the number of durations within each bin as an inline SVG
+// bar chart. The bins are evenly spread between `from` and `to`.
+func histogram(bins []int, from, to time.Duration) template.HTML {
+	highest := 0
+	for _, count := range bins {
+		if count > highest {
+			highest = count
+		}
+	}
+
+	svg := &strings.Builder{}
+	openChart(svg, formatDuration(float64(from)), formatDuration(float64(to)), "0", fmt.Sprint(highest))
+
+	barWidth := float64(chartWidth-2*chartMargin) / float64(len(bins))
+	for i, count := range bins {
+		if count == 0 {
+			continue
+		}
+
+		height := float64(count) / float64(highest) * (chartHeight - 2*chartMargin)
+		fmt.Fprintf(svg, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%d</title></rect>`,
+			float64(chartMargin)+float64(i)*barWidth,
+			float64(chartHeight-chartMargin)-height,
+			barWidth-1,
+			height,
+			palette[0],
+			count,
+		)
+	}
+
+	svg.WriteString("</svg>")
+	return template.HTML(svg.String())
+}
+
+// openChart writes the opening of the SVG element, with both axes and the
+// labels of their bounds.
+func openChart(svg *strings.Builder, minX, maxX, minY, maxY string) {
+	bottom := chartHeight - chartMargin
+	right := chartWidth - chartMargin
+
+	fmt.Fprintf(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-size="11" font-family="sans-serif">`, chartWidth, chartHeight)
+	fmt.Fprintf(svg, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#333"/>`, chartMargin, bottom, right, bottom)
+	fmt.Fprintf(svg, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#333"/>`, chartMargin, chartMargin, chartMargin, bottom)
+	fmt.Fprintf(svg, `<text x="%d" y="%d" text-anchor="start">%s</text>`, chartMargin, bottom+15, html.EscapeString(minX))
+	fmt.Fprintf(svg, `<text x="%d" y="%d" text-anchor="end">%s</text>`, right, bottom+15, html.EscapeString(maxX))
+	fmt.Fprintf(svg, `<text x="%d" y="%d" text-anchor="end">%s</text>`, chartMargin-5, bottom, html.EscapeString(minY))
+	fmt.Fprintf(svg, `<text x="%d" y="%d" text-anchor="end">%s</text>`, chartMargin-5, chartMargin+4, html.EscapeString(maxY))
+}
+
+func formatDuration(nanoseconds float64) string {
+	return time.Duration(nanoseconds).Round(time.Microsecond).String()
+}
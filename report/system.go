@@ -0,0 +1,92 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+
+	"github.com/tscolari/bender/runner"
+)
+
+// usage summarizes the samples of the host's usage during the run.
+type usage struct {
+	MeanCPU       float64
+	MaxCPU        float64
+	MeanIOWait    float64
+	MaxIOWait     float64
+	MaxMemoryUsed uint64
+	MaxLoad1      float64
+}
+
+func systemUsage(samples []runner.SystemSample) usage {
+	u := usage{}
+	for _, sample := range samples {
+		u.MeanCPU += sample.CPU / float64(len(samples))
+		u.MeanIOWait += sample.IOWait / float64(len(samples))
+
+		if sample.CPU > u.MaxCPU {
+			u.MaxCPU = sample.CPU
+		}
+		if sample.IOWait > u.MaxIOWait {
+			u.MaxIOWait = sample.IOWait
+		}
+		if sample.MemoryUsed > u.MaxMemoryUsed {
+			u.MaxMemoryUsed = sample.MemoryUsed
+		}
+		if sample.Load1 > u.MaxLoad1 {
+			u.MaxLoad1 = sample.Load1
+		}
+	}
+
+	return u
+}
+
+// systemCharts renders the CPU, memory, load and disk usage over time, relative
+// to the start of the run.
+func systemCharts(summary runner.Summary) []template.HTML {
+	cpu := series{Name: "cpu"}
+	iowait := series{Name: "iowait"}
+	memory := series{Name: "memory used"}
+	load := series{Name: "load 1m"}
+	read := series{Name: "disk read"}
+	write := series{Name: "disk write"}
+
+	for _, sample := range summary.System {
+		x := sample.Time.Sub(summary.StartTime).Seconds()
+		if x < 0 {
+			x = 0
+		}
+
+		cpu.Points = append(cpu.Points, point{X: x, Y: sample.CPU})
+		iowait.Points = append(iowait.Points, point{X: x, Y: sample.IOWait})
+		memory.Points = append(memory.Points, point{X: x, Y: float64(sample.MemoryUsed)})
+		load.Points = append(load.Points, point{X: x, Y: sample.Load1})
+		read.Points = append(read.Points, point{X: x, Y: sample.DiskReadRate})
+		write.Points = append(write.Points, point{X: x, Y: sample.DiskWriteRate})
+	}
+
+	seconds := func(x float64) string { return formatDuration(x * float64(time.Second)) }
+
+	return []template.HTML{
+		lineChart([]series{cpu, iowait}, seconds, func(y float64) string { return fmt.Sprintf("%.0f%%", y) }),
+		lineChart([]series{memory}, seconds, func(y float64) string { return formatBytes(uint64(y)) }),
+		lineChart([]series{load}, seconds, func(y float64) string { return fmt.Sprintf("%.2f", y) }),
+		lineChart([]series{read, write}, seconds, func(y float64) string { return formatBytes(uint64(y)) + "/s" }),
+	}
+}
+
+// formatBytes formats the bytes with a binary unit, e.g. 1.5 GiB.
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	value, exponent := float64(bytes)/unit, 0
+	for value >= unit && exponent < 4 {
+		value /= unit
+		exponent++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exponent])
+}
@@ -0,0 +1,229 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/commandrunner"
+	"code.cloudfoundry.org/commandrunner/linux_command_runner"
+)
+
+// Defines when an AdaptiveRunner considers the results stable
+// - Percentile is the statistic to estimate: 0 for the mean, or a percentile (0-100)
+// - Confidence is the confidence level of the intervals, e.g. 0.95
+// - TargetWidth is the maximum width of the confidence interval relative to the estimate, e.g. 0.05
+// - MinRuns is the minimum number of runs of each command before its precision is considered
+// - MaxRuns is the maximum number of runs, for all the commands together
+type AdaptiveOptions struct {
+	Percentile  float64
+	Confidence  float64
+	TargetWidth float64
+	MinRuns     int
+	MaxRuns     int
+}
+
+// Defines the precision achieved for the statistic of a command
+// - Statistic is the name of the statistic: "mean" or the percentile, e.g. "p95"
+// - Estimate is the value of the statistic
+// - Lower and Upper are the bounds of its confidence interval
+// - Confidence is the confidence level of the interval
+// - RelativeWidth is the width of the interval relative to the estimate
+// - Stable is true if RelativeWidth reached the target width
+type Precision struct {
+	Statistic     string        `json:"statistic"`
+	Estimate      time.Duration `json:"estimate"`
+	Lower         time.Duration `json:"lower"`
+	Upper         time.Duration `json:"upper"`
+	Confidence    float64       `json:"confidence"`
+	RelativeWidth float64       `json:"relative_width"`
+	Stable        bool          `json:"stable"`
+}
+
+// Parses the name of a statistic: "mean", or a percentile such as "p50" or "p99.9".
+// Returns 0 for the mean, or the percentile (0-100).
+func ParseStatistic(statistic string) (float64, error) {
+	if statistic == "mean" {
+		return 0, nil
+	}
+
+	if strings.HasPrefix(statistic, "p") {
+		p, err := strconv.ParseFloat(statistic[1:], 64)
+		if err == nil && p > 0 && p < 100 {
+			return p, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid statistic `%s`: use `mean` or a percentile such as `p95`", statistic)
+}
+
+// AdaptiveRunner defines a runner that keeps running the commands until the
+// confidence interval of the chosen statistic is narrower than the target width
+// for every command, or the maximum number of runs is reached.
+// The achieved precision is reported in each of the Summary.Commands.
+type AdaptiveRunner struct {
+	baseRunner
+	options AdaptiveOptions
+}
+
+// Creates a new instance of the AdaptiveRunner.
+func NewAdaptiveRunner(options AdaptiveOptions) *AdaptiveRunner {
+	cmdRunner := linux_command_runner.New()
+	return NewAdaptiveRunnerWithCmdRunner(cmdRunner, options)
+}
+
+func NewAdaptiveRunnerWithCmdRunner(cmdRunner commandrunner.CommandRunner, options AdaptiveOptions) *AdaptiveRunner {
+	baseRunner := newBaseRunner(cmdRunner)
+
+	return &AdaptiveRunner{
+		baseRunner: baseRunner,
+		options:    options,
+	}
+}
+
+// Start commands execution.
+// This method will block until all commands are stable, the maximum number of runs
+// is reached or `cancel` is closed. It will then wait for any running command to
+// finish and summarize the results.
+func (r *AdaptiveRunner) Run(concurrency int, cancel chan bool, commands ...string) (Summary, error) {
+	if len(commands) == 0 {
+		return Summary{}, errors.New("no commands given")
+	}
+
+	if r.options.Confidence <= 0 || r.options.Confidence >= 1 {
+		return Summary{}, errors.New("confidence must be between 0 and 1")
+	}
+
+	if r.options.TargetWidth <= 0 {
+		return Summary{}, errors.New("target width must be positive")
+	}
+
+	if r.options.MaxRuns < 1 {
+		return Summary{}, errors.New("max runs must be at least 1")
+	}
+
+	summary := Summary{
+		Commands: r.commandsSummary(commands),
+	}
+
+	start := time.Now()
+	summary.StartTime = start
+	wg := sync.WaitGroup{}
+
+	tasks := make(chan bool, r.options.MaxRuns)
+	stats := make(chan RunStats, 1000)
+	stable := make(chan bool)
+
+	for i := 0; i < r.options.MaxRuns; i++ {
+		tasks <- true
+	}
+
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		worker := i + 1
+		go func() {
+			r.startWorker(worker, tasks, cancel, stable, stats, commands)
+			wg.Done()
+		}()
+	}
+
+	mergeStatsDone := make(chan bool)
+	go func() {
+		r.mergeUntilStable(stats, stable, &summary)
+		close(mergeStatsDone)
+	}()
+
+	wg.Wait()
+	summary.Duration = time.Since(start)
+	close(stats)
+	<-mergeStatsDone
+	return summary, nil
+}
+
+// mergeUntilStable merges the stats into the summary, closing `stable` once
+// every command reached the target precision.
+func (r *AdaptiveRunner) mergeUntilStable(stats chan RunStats, stable chan bool, summary *Summary) {
+	durations := map[int][]time.Duration{}
+	precisions := map[int]Precision{}
+	isStable := false
+
+	for runStats := range stats {
+		r.mergeRunstats(runStats, summary)
+
+		durations[runStats.Command] = append(durations[runStats.Command], runStats.Duration)
+		precisions[runStats.Command] = r.precision(durations[runStats.Command])
+
+		if !isStable && r.allStable(summary.Commands, precisions) {
+			isStable = true
+			close(stable)
+		}
+	}
+
+	for i, precision := range precisions {
+		precision := precision
+		cmd := summary.Commands[i]
+		cmd.Precision = &precision
+		summary.Commands[i] = cmd
+	}
+}
+
+func (r *AdaptiveRunner) allStable(commands map[int]Command, precisions map[int]Precision) bool {
+	for i, cmd := range commands {
+		if cmd.RunCount < r.options.MinRuns || !precisions[i].Stable {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *AdaptiveRunner) precision(durations []time.Duration) Precision {
+	precision := Precision{
+		Statistic:  "mean",
+		Confidence: r.options.Confidence,
+	}
+	reliable := len(durations) > 1
+
+	if r.options.Percentile > 0 {
+		sorted := make([]time.Duration, len(durations))
+		copy(sorted, durations)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		precision.Statistic = "p" + strconv.FormatFloat(r.options.Percentile, 'f', -1, 64)
+		precision.Estimate = percentile(sorted, r.options.Percentile)
+		precision.Lower, precision.Upper, reliable = percentileInterval(sorted, r.options.Percentile, r.options.Confidence)
+	} else {
+		precision.Estimate = mean(durations)
+		precision.Lower, precision.Upper = meanInterval(durations, r.options.Confidence)
+	}
+
+	if precision.Estimate > 0 {
+		precision.RelativeWidth = float64(precision.Upper-precision.Lower) / float64(precision.Estimate)
+	}
+
+	precision.Stable = reliable && precision.RelativeWidth <= r.options.TargetWidth
+	return precision
+}
+
+func (r *AdaptiveRunner) startWorker(worker int, tasks chan bool, cancel chan bool, stable chan bool, stats chan RunStats, commands []string) {
+	for iteration := 1; ; iteration++ {
+		select {
+		case <-cancel:
+			return
+		case <-stable:
+			return
+		default:
+		}
+
+		select {
+		case <-tasks:
+			stats <- r.run(commands, worker, iteration)
+		default:
+			return
+		}
+	}
+}
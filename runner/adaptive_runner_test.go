@@ -0,0 +1,217 @@
+package runner_test
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/commandrunner/fake_command_runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("AdaptiveRunner", func() {
+	var (
+		commands       []string
+		cancelChan     chan bool
+		cmdRunner      *fake_command_runner.FakeCommandRunner
+		adaptiveRunner *runner.AdaptiveRunner
+		options        runner.AdaptiveOptions
+
+		commandFunc func(cmd *exec.Cmd) error
+	)
+
+	BeforeEach(func() {
+		cancelChan = make(chan bool)
+		cmdRunner = fake_command_runner.New()
+		commands = []string{"hello world"}
+		commandFunc = func(_ *exec.Cmd) error {
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}
+
+		options = runner.AdaptiveOptions{
+			Confidence:  0.95,
+			TargetWidth: 0.1,
+			MinRuns:     10,
+			MaxRuns:     100,
+		}
+	})
+
+	JustBeforeEach(func() {
+		adaptiveRunner = runner.NewAdaptiveRunnerWithCmdRunner(cmdRunner, options)
+
+		cmdRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: strings.Split(commands[0], " ")[0],
+			Args: strings.Split(commands[0], " ")[1:],
+		}, func(cmd *exec.Cmd) error {
+			return commandFunc(cmd)
+		})
+	})
+
+	Describe("ParseStatistic", func() {
+		It("parses the mean", func() {
+			Expect(runner.ParseStatistic("mean")).To(Equal(0.0))
+		})
+
+		It("parses percentiles", func() {
+			Expect(runner.ParseStatistic("p95")).To(Equal(95.0))
+			Expect(runner.ParseStatistic("p99.9")).To(Equal(99.9))
+		})
+
+		It("fails on anything else", func() {
+			_, err := runner.ParseStatistic("p100")
+			Expect(err).To(MatchError("invalid statistic `p100`: use `mean` or a percentile such as `p95`"))
+
+			_, err = runner.ParseStatistic("median")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Run", func() {
+		Context("when the durations are stable", func() {
+			It("stops before the maximum runs are reached", func() {
+				summary, err := adaptiveRunner.Run(1, cancelChan, commands...)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(summary.SuccessCounter).To(BeNumerically(">=", 10))
+				Expect(summary.SuccessCounter).To(BeNumerically("<", 100))
+			})
+
+			It("reports the achieved precision", func() {
+				summary, err := adaptiveRunner.Run(1, cancelChan, commands...)
+				Expect(err).NotTo(HaveOccurred())
+
+				precision := summary.Commands[1].Precision
+				Expect(precision).NotTo(BeNil())
+				Expect(precision.Statistic).To(Equal("mean"))
+				Expect(precision.Confidence).To(Equal(0.95))
+				Expect(precision.Stable).To(BeTrue())
+				Expect(precision.RelativeWidth).To(BeNumerically("<=", 0.1))
+				Expect(precision.Estimate).To(BeNumerically("~", 10*time.Millisecond, 5*time.Millisecond))
+				Expect(precision.Lower).To(BeNumerically("<=", precision.Estimate))
+				Expect(precision.Upper).To(BeNumerically(">=", precision.Estimate))
+			})
+		})
+
+		Context("when the durations are noisy", func() {
+			BeforeEach(func() {
+				options.TargetWidth = 0.01
+				options.MaxRuns = 30
+
+				lock := &sync.Mutex{}
+				i := 0
+				commandFunc = func(_ *exec.Cmd) error {
+					lock.Lock()
+					i++
+					sleep := time.Duration(1+(i%2)*20) * time.Millisecond
+					lock.Unlock()
+
+					time.Sleep(sleep)
+					return nil
+				}
+			})
+
+			It("stops at the maximum runs", func() {
+				summary, err := adaptiveRunner.Run(2, cancelChan, commands...)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(summary.SuccessCounter).To(Equal(30))
+				Expect(summary.Commands[1].Precision.Stable).To(BeFalse())
+				Expect(summary.Commands[1].Precision.RelativeWidth).To(BeNumerically(">", 0.01))
+			})
+		})
+
+		Context("when a percentile is chosen", func() {
+			BeforeEach(func() {
+				options.Percentile = 50
+				options.MaxRuns = 40
+			})
+
+			It("estimates the percentile", func() {
+				summary, err := adaptiveRunner.Run(1, cancelChan, commands...)
+				Expect(err).NotTo(HaveOccurred())
+
+				precision := summary.Commands[1].Precision
+				Expect(precision.Statistic).To(Equal("p50"))
+				Expect(precision.Stable).To(BeTrue())
+				Expect(summary.SuccessCounter).To(BeNumerically(">=", 10))
+				Expect(summary.SuccessCounter).To(BeNumerically("<", 40))
+			})
+		})
+
+		Context("running multiple commands", func() {
+			BeforeEach(func() {
+				commands = []string{"command 1", "command 2"}
+				cmdRunner.WhenRunning(fake_command_runner.CommandSpec{
+					Path: "command",
+					Args: []string{"2"},
+				}, func(cmd *exec.Cmd) error {
+					time.Sleep(5 * time.Millisecond)
+					return nil
+				})
+			})
+
+			It("runs until every command is stable", func() {
+				summary, err := adaptiveRunner.Run(1, cancelChan, commands...)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(summary.Commands[1].RunCount).To(BeNumerically(">=", 10))
+				Expect(summary.Commands[2].RunCount).To(BeNumerically(">=", 10))
+				Expect(summary.Commands[1].Precision.Stable).To(BeTrue())
+				Expect(summary.Commands[2].Precision.Stable).To(BeTrue())
+			})
+		})
+
+		Context("when the options are invalid", func() {
+			It("validates the confidence", func() {
+				options.Confidence = 1
+				_, err := runner.NewAdaptiveRunnerWithCmdRunner(cmdRunner, options).Run(1, cancelChan, commands...)
+				Expect(err).To(MatchError("confidence must be between 0 and 1"))
+			})
+
+			It("validates the target width", func() {
+				options.TargetWidth = 0
+				_, err := runner.NewAdaptiveRunnerWithCmdRunner(cmdRunner, options).Run(1, cancelChan, commands...)
+				Expect(err).To(MatchError("target width must be positive"))
+			})
+
+			It("validates the max runs", func() {
+				options.MaxRuns = 0
+				_, err := runner.NewAdaptiveRunnerWithCmdRunner(cmdRunner, options).Run(1, cancelChan, commands...)
+				Expect(err).To(MatchError("max runs must be at least 1"))
+			})
+		})
+
+		Context("when there's no command given", func() {
+			It("returns an error", func() {
+				_, err := adaptiveRunner.Run(1, cancelChan)
+				Expect(err).To(MatchError("no commands given"))
+			})
+		})
+
+		Context("cancelling", func() {
+			BeforeEach(func() {
+				options.TargetWidth = 0.0001
+				options.MaxRuns = 100000
+			})
+
+			It("stops running", func() {
+				finished := make(chan bool)
+				go func() {
+					defer GinkgoRecover()
+					_, err := adaptiveRunner.Run(1, cancelChan, commands...)
+					Expect(err).NotTo(HaveOccurred())
+					close(finished)
+				}()
+
+				Consistently(finished, 100*time.Millisecond).ShouldNot(BeClosed())
+				close(cancelChan)
+				Eventually(finished).Should(BeClosed())
+			})
+		})
+	})
+})
@@ -0,0 +1,340 @@
+package runner
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Period of the CPU quota of the cgroups, the kernel's default.
+const cgroupCPUPeriod = 100000
+
+// Defines the cgroup v2 the commands run in
+// - Parent is the cgroup v2 directory the cgroups are created in, e.g. /sys/fs/cgroup/bender. It must be writable and have no processes
+// - PerRun runs each command in its own cgroup, otherwise all the runs share one cgroup for the whole benchmark
+// - CPUs is the maximum number of CPUs the cgroup can use, e.g. 1.5. 0 means no limit
+// - MemoryMax is the maximum memory of the cgroup, in bytes or with a K, M or G suffix, e.g. 512M. Empty means no limit
+// - IOWeight is the IO weight of the cgroup, from 1 to 10000. 0 leaves the default weight
+type CgroupOptions struct {
+	Parent    string
+	PerRun    bool
+	CPUs      float64
+	MemoryMax string
+	IOWeight  int
+}
+
+// Defines the resource usage accounted by a cgroup
+// - CPUUsage is the total CPU time used
+// - UserTime and SystemTime split the CPU time used in user and kernel mode
+// - MemoryPeak is the highest memory usage in bytes (requires the memory controller and Linux 5.19)
+// - OOMKills is the number of processes killed for going over the memory limit
+type CgroupStats struct {
+	CPUUsage   time.Duration `json:"cpu_usage"`
+	UserTime   time.Duration `json:"user_time"`
+	SystemTime time.Duration `json:"system_time"`
+	MemoryPeak uint64        `json:"memory_peak,omitempty"`
+	OOMKills   int           `json:"oom_kills,omitempty"`
+}
+
+// Cgroup is a Hook that runs the commands in a cgroup v2 with the given limits,
+// and adds the CPU and memory accounting of each run's cgroup to the RunStats.
+// Its cgroups are created in a `bender-<pid>` cgroup under the parent, which is
+// removed on Close. Only supported on Linux.
+type Cgroup struct {
+	options CgroupOptions
+	root    string
+	file    *os.File
+
+	mutex   sync.Mutex
+	created int
+	running map[*exec.Cmd]*cgroupRun
+}
+
+type cgroupRun struct {
+	path string
+	file *os.File
+}
+
+// CgroupMount returns where the cgroup v2 hierarchy is mounted: /sys/fs/cgroup on
+// hosts with only cgroup v2, or /sys/fs/cgroup/unified on hybrid hosts.
+func CgroupMount() (string, error) {
+	for _, mount := range []string{"/sys/fs/cgroup", "/sys/fs/cgroup/unified"} {
+		if _, err := os.Stat(filepath.Join(mount, "cgroup.controllers")); err == nil {
+			return mount, nil
+		}
+	}
+
+	return "", errors.New("cgroup v2 isn't mounted")
+}
+
+// Creates a new instance of the Cgroup, creating the cgroup of the benchmark and
+// enabling the controllers the limits need.
+func NewCgroup(options CgroupOptions) (*Cgroup, error) {
+	if !cgroupsSupported {
+		return nil, errors.New("cgroups are only supported on Linux")
+	}
+
+	if options.CPUs < 0 {
+		return nil, errors.New("the CPU limit can't be negative")
+	}
+
+	if options.IOWeight < 0 || options.IOWeight > 10000 {
+		return nil, errors.New("the IO weight must be between 1 and 10000")
+	}
+
+	if _, err := os.Stat(filepath.Join(options.Parent, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("`%s` isn't a cgroup v2 directory", options.Parent)
+	}
+
+	c := &Cgroup{
+		options: options,
+		root:    filepath.Join(options.Parent, fmt.Sprintf("bender-%d", os.Getpid())),
+		running: map[*exec.Cmd]*cgroupRun{},
+	}
+
+	if err := c.enableControllers(options.Parent); err != nil {
+		return nil, err
+	}
+
+	if err := os.Mkdir(c.root, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := c.setup(); err != nil {
+		removeCgroup(c.root)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// setup applies the limits to the cgroup of the benchmark, or, when each run has
+// its own cgroup, to a probe cgroup, so invalid limits fail before any run.
+func (c *Cgroup) setup() error {
+	if !c.options.PerRun {
+		if err := c.applyLimits(c.root); err != nil {
+			return err
+		}
+
+		file, err := os.Open(c.root)
+		c.file = file
+		return err
+	}
+
+	if err := c.enableControllers(c.root); err != nil {
+		return err
+	}
+
+	probe := filepath.Join(c.root, "probe")
+	if err := os.Mkdir(probe, 0755); err != nil {
+		return err
+	}
+	defer removeCgroup(probe)
+
+	return c.applyLimits(probe)
+}
+
+// Before places the command in its cgroup. If the cgroup can't be created the
+// command fails to start, rather than running without the limits.
+func (c *Cgroup) Before(cmd *exec.Cmd, _ *RunStats) {
+	run := &cgroupRun{file: c.file}
+
+	if c.options.PerRun {
+		c.mutex.Lock()
+		c.created++
+		run.path = filepath.Join(c.root, fmt.Sprintf("run-%d", c.created))
+		c.mutex.Unlock()
+
+		file, err := c.createRunCgroup(run.path)
+		if err != nil {
+			cmd.Err = fmt.Errorf("failed to create the cgroup: %s", err.Error())
+			return
+		}
+		run.file = file
+	}
+
+	attachCgroup(cmd, int(run.file.Fd()))
+
+	c.mutex.Lock()
+	c.running[cmd] = run
+	c.mutex.Unlock()
+}
+
+// After adds the accounting of the run's cgroup to the stats and removes it,
+// when each run has its own cgroup.
+func (c *Cgroup) After(cmd *exec.Cmd, stats *RunStats) {
+	c.mutex.Lock()
+	run, ok := c.running[cmd]
+	delete(c.running, cmd)
+	c.mutex.Unlock()
+
+	if !ok || !c.options.PerRun {
+		return
+	}
+
+	stats.Cgroup = readCgroupStats(run.path)
+	run.file.Close()
+	removeCgroup(run.path)
+}
+
+// Close removes the cgroups, killing any process left in them.
+// When the runs share the cgroup of the benchmark, its accounting is returned.
+func (c *Cgroup) Close() (*CgroupStats, error) {
+	var stats *CgroupStats
+	if !c.options.PerRun {
+		stats = readCgroupStats(c.root)
+		c.file.Close()
+	}
+
+	return stats, removeCgroup(c.root)
+}
+
+func (c *Cgroup) createRunCgroup(path string) (*os.File, error) {
+	if err := os.Mkdir(path, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyLimits(path); err != nil {
+		removeCgroup(path)
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		removeCgroup(path)
+	}
+	return file, err
+}
+
+// enableControllers enables the controllers the limits need for the children of
+// the cgroup, and the memory controller for its accounting, if available.
+func (c *Cgroup) enableControllers(dir string) error {
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+
+	available := map[string]bool{}
+	for _, controller := range strings.Fields(string(contents)) {
+		available[controller] = true
+	}
+
+	required := map[string]bool{
+		"cpu":    c.options.CPUs > 0,
+		"memory": c.options.MemoryMax != "",
+		"io":     c.options.IOWeight > 0,
+	}
+
+	for _, controller := range []string{"cpu", "memory", "io"} {
+		if !required[controller] && (controller != "memory" || !available[controller]) {
+			continue
+		}
+
+		if !available[controller] {
+			return fmt.Errorf("the `%s` controller isn't available in `%s`", controller, dir)
+		}
+
+		err := writeCgroupFile(dir, "cgroup.subtree_control", "+"+controller)
+		if err != nil && required[controller] {
+			return fmt.Errorf("failed to enable the `%s` controller in `%s`: %s", controller, dir, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (c *Cgroup) applyLimits(dir string) error {
+	if c.options.CPUs > 0 {
+		quota := int(c.options.CPUs * cgroupCPUPeriod)
+		if err := writeCgroupFile(dir, "cpu.max", fmt.Sprintf("%d %d", quota, cgroupCPUPeriod)); err != nil {
+			return fmt.Errorf("failed to limit the CPUs: %s", err.Error())
+		}
+	}
+
+	if c.options.MemoryMax != "" {
+		if err := writeCgroupFile(dir, "memory.max", c.options.MemoryMax); err != nil {
+			return fmt.Errorf("failed to limit the memory: %s", err.Error())
+		}
+	}
+
+	if c.options.IOWeight > 0 {
+		if err := writeCgroupFile(dir, "io.weight", fmt.Sprintf("default %d", c.options.IOWeight)); err != nil {
+			return fmt.Errorf("failed to set the IO weight: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	return ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+}
+
+// removeCgroup removes the cgroup. If it still has processes, e.g. left in the
+// background by a command, they are killed first.
+func removeCgroup(path string) error {
+	err := os.Remove(path)
+	if err == nil || os.IsNotExist(err) {
+		return nil
+	}
+
+	if killErr := writeCgroupFile(path, "cgroup.kill", "1"); killErr != nil {
+		return err
+	}
+
+	// The processes are killed asynchronously, so the cgroup is only empty once
+	// all of them exited.
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if err = os.Remove(path); err == nil || os.IsNotExist(err) {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func readCgroupStats(dir string) *CgroupStats {
+	stats := &CgroupStats{
+		CPUUsage:   time.Duration(readCgroupValue(filepath.Join(dir, "cpu.stat"), "usage_usec")) * time.Microsecond,
+		UserTime:   time.Duration(readCgroupValue(filepath.Join(dir, "cpu.stat"), "user_usec")) * time.Microsecond,
+		SystemTime: time.Duration(readCgroupValue(filepath.Join(dir, "cpu.stat"), "system_usec")) * time.Microsecond,
+		OOMKills:   int(readCgroupValue(filepath.Join(dir, "memory.events"), "oom_kill")),
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "memory.peak"))
+	if err == nil {
+		stats.MemoryPeak, _ = strconv.ParseUint(strings.TrimSpace(string(contents)), 10, 64)
+	}
+
+	return stats
+}
+
+// readCgroupValue returns the number of the `key value` line of the file with
+// the given key, or 0 if it can't be read.
+func readCgroupValue(path, key string) uint64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			value, _ := strconv.ParseUint(fields[1], 10, 64)
+			return value
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,22 @@
+//go:build linux
+// +build linux
+
+package runner
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+const cgroupsSupported = true
+
+// attachCgroup makes the command start directly in the cgroup of the file
+// descriptor, so none of its processes run outside of it.
+func attachCgroup(cmd *exec.Cmd, fd int) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = fd
+}
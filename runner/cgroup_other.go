@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package runner
+
+import "os/exec"
+
+const cgroupsSupported = false
+
+func attachCgroup(_ *exec.Cmd, _ int) {}
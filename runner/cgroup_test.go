@@ -0,0 +1,183 @@
+package runner_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("Cgroup", func() {
+	var (
+		dir    string
+		parent string
+		root   string
+	)
+
+	BeforeEach(func() {
+		if runtime.GOOS != "linux" {
+			Skip("cgroups are only supported on Linux")
+		}
+
+		mount, err := runner.CgroupMount()
+		if err != nil {
+			Skip(err.Error())
+		}
+
+		parent = filepath.Join(mount, fmt.Sprintf("bender-test-%d", os.Getpid()))
+		if err := os.Mkdir(parent, 0755); err != nil {
+			Skip("can't create a cgroup v2")
+		}
+		root = filepath.Join(parent, fmt.Sprintf("bender-%d", os.Getpid()))
+
+		dir, err = ioutil.TempDir("", "bender-cgroup")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+		if parent != "" {
+			os.Remove(root)
+			Expect(os.Remove(parent)).To(Succeed())
+		}
+	})
+
+	// writeScript writes a script that busies the CPU and records the cgroup it
+	// ran in.
+	writeScript := func(body string) string {
+		script := filepath.Join(dir, "script.sh")
+		contents := "#!/bin/sh\ni=0\nwhile [ $i -lt 20000 ]; do i=$((i+1)); done\n" +
+			"cat /proc/self/cgroup | grep '^0::' >> " + filepath.Join(dir, "cgroups") + "\n" + body
+		Expect(ioutil.WriteFile(script, []byte(contents), 0755)).To(Succeed())
+		return script
+	}
+
+	readCgroups := func() []string {
+		contents, err := ioutil.ReadFile(filepath.Join(dir, "cgroups"))
+		Expect(err).NotTo(HaveOccurred())
+		return strings.Split(strings.TrimSpace(string(contents)), "\n")
+	}
+
+	Context("when each run has its own cgroup", func() {
+		It("runs every command in a new cgroup and adds its usage", func() {
+			cgroup, err := runner.NewCgroup(runner.CgroupOptions{Parent: parent, PerRun: true})
+			Expect(err).NotTo(HaveOccurred())
+
+			countRunner := runner.NewCountRunner(3)
+			countRunner.AddHook(cgroup)
+
+			summary, err := countRunner.Run(2, make(chan bool), writeScript(""))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.EachRun).To(HaveLen(3))
+
+			for _, runStats := range summary.EachRun {
+				Expect(runStats.Failed).To(BeFalse())
+				Expect(runStats.Cgroup).NotTo(BeNil())
+				Expect(runStats.Cgroup.CPUUsage).To(BeNumerically(">", 0))
+				Expect(runStats.Cgroup.UserTime + runStats.Cgroup.SystemTime).To(BeNumerically("<=", runStats.Cgroup.CPUUsage+time.Millisecond))
+			}
+
+			cgroups := readCgroups()
+			Expect(cgroups).To(HaveLen(3))
+			for _, line := range cgroups {
+				Expect(line).To(MatchRegexp(`/bender-%d/run-\d+$`, os.Getpid()))
+			}
+			Expect(cgroups[0]).NotTo(Equal(cgroups[1]))
+
+			stats, err := cgroup.Close()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats).To(BeNil())
+			Expect(root).NotTo(BeADirectory())
+		})
+
+		It("kills the processes a run left behind", func() {
+			cgroup, err := runner.NewCgroup(runner.CgroupOptions{Parent: parent, PerRun: true})
+			Expect(err).NotTo(HaveOccurred())
+
+			countRunner := runner.NewCountRunner(1)
+			countRunner.AddHook(cgroup)
+
+			start := time.Now()
+			summary, err := countRunner.Run(1, make(chan bool), writeScript("sleep 10 >/dev/null 2>&1 &\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.EachRun[0].Failed).To(BeFalse())
+			Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+
+			entries, err := ioutil.ReadDir(root)
+			Expect(err).NotTo(HaveOccurred())
+			for _, entry := range entries {
+				Expect(entry.Name()).NotTo(HavePrefix("run-"))
+			}
+
+			_, err = cgroup.Close()
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("when the runs share the cgroup of the benchmark", func() {
+		It("runs every command in it and returns its usage on close", func() {
+			cgroup, err := runner.NewCgroup(runner.CgroupOptions{Parent: parent})
+			Expect(err).NotTo(HaveOccurred())
+
+			countRunner := runner.NewCountRunner(3)
+			countRunner.AddHook(cgroup)
+
+			summary, err := countRunner.Run(2, make(chan bool), writeScript(""))
+			Expect(err).NotTo(HaveOccurred())
+			for _, runStats := range summary.EachRun {
+				Expect(runStats.Failed).To(BeFalse())
+				Expect(runStats.Cgroup).To(BeNil())
+			}
+
+			Expect(readCgroups()).To(ConsistOf(
+				HaveSuffix(fmt.Sprintf("/bender-%d", os.Getpid())),
+				HaveSuffix(fmt.Sprintf("/bender-%d", os.Getpid())),
+				HaveSuffix(fmt.Sprintf("/bender-%d", os.Getpid())),
+			))
+
+			stats, err := cgroup.Close()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats).NotTo(BeNil())
+			Expect(stats.CPUUsage).To(BeNumerically(">", 0))
+			Expect(root).NotTo(BeADirectory())
+		})
+	})
+
+	Context("when a limit's controller isn't available", func() {
+		It("returns an error", func() {
+			controllers, err := ioutil.ReadFile(filepath.Join(parent, "cgroup.controllers"))
+			Expect(err).NotTo(HaveOccurred())
+			if strings.Contains(string(controllers), "cpu") {
+				Skip("the cpu controller is available")
+			}
+
+			_, err = runner.NewCgroup(runner.CgroupOptions{Parent: parent, CPUs: 1})
+			Expect(err).To(MatchError(ContainSubstring("the `cpu` controller isn't available")))
+			Expect(root).NotTo(BeADirectory())
+		})
+	})
+
+	Context("when the parent isn't a cgroup v2", func() {
+		It("returns an error", func() {
+			_, err := runner.NewCgroup(runner.CgroupOptions{Parent: dir})
+			Expect(err).To(MatchError(fmt.Sprintf("`%s` isn't a cgroup v2 directory", dir)))
+		})
+	})
+
+	Context("when the limits are invalid", func() {
+		It("returns an error", func() {
+			_, err := runner.NewCgroup(runner.CgroupOptions{Parent: parent, CPUs: -1})
+			Expect(err).To(MatchError("the CPU limit can't be negative"))
+
+			_, err = runner.NewCgroup(runner.CgroupOptions{Parent: parent, IOWeight: 20000})
+			Expect(err).To(MatchError("the IO weight must be between 1 and 10000"))
+		})
+	})
+})
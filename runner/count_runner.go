@@ -45,6 +45,7 @@ func (r *CountRunner) Run(concurrency int, cancel chan bool, commands ...string)
 	}
 
 	start := time.Now()
+	summary.StartTime = start
 	wg := sync.WaitGroup{}
 
 	tasks := make(chan bool, r.counter)
@@ -56,8 +57,9 @@ func (r *CountRunner) Run(concurrency int, cancel chan bool, commands ...string)
 
 	wg.Add(concurrency)
 	for i := 0; i < concurrency; i++ {
+		worker := i + 1
 		go func() {
-			r.startWorker(tasks, cancel, stats, commands)
+			r.startWorker(worker, tasks, cancel, stats, commands)
 			wg.Done()
 		}()
 	}
@@ -70,11 +72,11 @@ func (r *CountRunner) Run(concurrency int, cancel chan bool, commands ...string)
 	return summary, nil
 }
 
-func (r *CountRunner) startWorker(tasks chan bool, stop chan bool, stats chan RunStats, commands []string) {
-	for {
+func (r *CountRunner) startWorker(worker int, tasks chan bool, stop chan bool, stats chan RunStats, commands []string) {
+	for iteration := 1; ; iteration++ {
 		select {
 		case <-tasks:
-			stats <- r.run(commands)
+			stats <- r.run(commands, worker, iteration)
 		case <-stop:
 			return
 		default:
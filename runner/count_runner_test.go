@@ -129,6 +129,7 @@ var _ = Describe("CountRunner", func() {
 			Expect(summary.EachRun[1].Command).To(Equal(1))
 			Expect(summary.EachRun[1].Duration).To(BeNumerically("~", 10*time.Millisecond, 5*time.Millisecond))
 			Expect(summary.EachRun[1].Failed).To(BeTrue())
+			Expect(summary.EachRun[1].ExitCode).To(Equal(-1))
 			Expect(summary.EachRun[1].StartTime.UnixNano()).To(BeNumerically(">", summary.EachRun[0].StartTime.UnixNano()))
 
 			Expect(summary.EachRun[2].Command).To(Equal(1))
@@ -147,6 +148,88 @@ var _ = Describe("CountRunner", func() {
 			Expect(summary.EachRun[4].StartTime.UnixNano()).To(BeNumerically(">", summary.EachRun[3].StartTime.UnixNano()))
 		})
 
+		It("records the worker and iteration of each run", func() {
+			summary, err := countRunner.Run(1, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.EachRun).To(HaveLen(count))
+			for i, runStats := range summary.EachRun {
+				Expect(runStats.Worker).To(Equal(1))
+				Expect(runStats.Iteration).To(Equal(i + 1))
+				Expect(runStats.ScheduleDelay).To(BeNumerically(">=", 0))
+				Expect(runStats.ScheduleDelay).To(BeNumerically("<", time.Millisecond))
+			}
+		})
+
+		It("keeps the end of the stderr of the failed runs", func() {
+			mutex := &sync.Mutex{}
+			i := 0
+
+			commandFunc = func(cmd *exec.Cmd) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				i++
+
+				cmd.Stderr.Write([]byte(strings.Repeat("a", 2000) + "boom\n"))
+				if i%2 == 0 {
+					return errors.New("failed")
+				}
+				return nil
+			}
+
+			summary, err := countRunner.Run(1, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.EachRun[0].Stderr).To(BeEmpty())
+			Expect(summary.EachRun[1].Stderr).To(HaveLen(1024))
+			Expect(summary.EachRun[1].Stderr).To(HaveSuffix("aaaboom\n"))
+		})
+
+		It("notifies the observers of each run", func() {
+			observer := &fakeObserver{}
+			countRunner.AddObserver(observer)
+
+			_, err := countRunner.Run(2, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(observer.commands).To(HaveLen(count))
+			Expect(observer.commands[0]).To(Equal("hello world"))
+		})
+
+		It("calls the hooks around each run", func() {
+			hook := &fakeHook{}
+			countRunner.AddHook(hook)
+
+			var env []string
+			commandFunc = func(cmd *exec.Cmd) error {
+				env = cmd.Env
+				return errors.New("failed")
+			}
+
+			_, err := countRunner.Run(1, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(env).To(ContainElement("HOOKED=true"))
+
+			Expect(hook.before).To(Equal(count))
+			Expect(hook.after).To(HaveLen(count))
+			Expect(hook.after[0].Failed).To(BeTrue())
+			Expect(hook.after[0].Iteration).To(Equal(1))
+		})
+
+		It("starts and waits for the commands when a hook needs the process", func() {
+			hook := &fakeStartHook{}
+			countRunner.AddHook(hook)
+
+			_, err := countRunner.Run(1, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(cmdRunner.StartedCommands()).To(HaveLen(count))
+			Expect(cmdRunner.ExecutedCommands()).To(BeEmpty())
+			Expect(hook.started).To(Equal(count))
+			Expect(hook.after).To(HaveLen(count))
+		})
+
 		Context("when there's no command given", func() {
 			It("summarizes the commands it ran", func() {
 				_, err := countRunner.Run(1, cancelChan)
@@ -257,6 +340,65 @@ var _ = Describe("CountRunner", func() {
 				Expect(summary.EachRun[4].Duration).To(BeNumerically("~", 10*time.Millisecond, 5*time.Millisecond))
 				Expect(summary.EachRun[4].StartTime.UnixNano()).To(BeNumerically("~", start.UnixNano(), time.Millisecond))
 			})
+
+			It("spreads the runs across the workers", func() {
+				commandFunc = func(_ *exec.Cmd) error {
+					time.Sleep(10 * time.Millisecond)
+					return nil
+				}
+
+				summary, err := countRunner.Run(3, cancelChan, commands...)
+				Expect(err).NotTo(HaveOccurred())
+
+				runsPerWorker := map[int]int{}
+				for _, runStats := range summary.EachRun {
+					runsPerWorker[runStats.Worker]++
+				}
+				Expect(runsPerWorker).To(Equal(map[int]int{1: 2, 2: 2, 3: 2}))
+			})
 		})
 	})
 })
+
+type fakeObserver struct {
+	mutex    sync.Mutex
+	commands []string
+	stats    []runner.RunStats
+}
+
+func (o *fakeObserver) Observe(command string, stats runner.RunStats) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.commands = append(o.commands, command)
+	o.stats = append(o.stats, stats)
+}
+
+type fakeHook struct {
+	mutex  sync.Mutex
+	before int
+	after  []runner.RunStats
+}
+
+func (h *fakeHook) Before(cmd *exec.Cmd, _ *runner.RunStats) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.before++
+	cmd.Env = append(cmd.Env, "HOOKED=true")
+}
+
+func (h *fakeHook) After(_ *exec.Cmd, stats *runner.RunStats) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.after = append(h.after, *stats)
+}
+
+type fakeStartHook struct {
+	fakeHook
+	started int
+}
+
+func (h *fakeStartHook) Started(_ *exec.Cmd, _ *runner.RunStats) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.started++
+}
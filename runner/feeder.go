@@ -0,0 +1,242 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defines where a Feeder reads its rows from and how it picks them
+// - Path is the CSV file, with a header naming the fields, or JSON lines file (.jsonl, .ndjson or .json) to read
+// - Order is how the rows are picked: "sequential" (each row once), "circular" (in order, starting over at the end) or "random"
+// - Unique gives each worker its own share of the rows, so no two workers use the same row
+// - Workers is the number of workers sharing the rows when Unique is set
+type FeederOptions struct {
+	Path    string
+	Order   string
+	Unique  bool
+	Workers int
+}
+
+// Feeder is a Hook that replaces the {{field}} placeholders in the arguments of
+// every run with the fields of a row read from a file, and records the row in
+// the RunStats. The commands are split into arguments before the placeholders
+// are replaced, so a field with spaces is a single argument.
+type Feeder struct {
+	options FeederOptions
+	fields  []string
+	rows    []map[string]string
+
+	mutex  sync.Mutex
+	next   map[int]int
+	random *rand.Rand
+}
+
+// Creates a new instance of the Feeder, reading all the rows of the file.
+func NewFeeder(options FeederOptions) (*Feeder, error) {
+	if options.Order != "sequential" && options.Order != "circular" && options.Order != "random" {
+		return nil, fmt.Errorf("invalid feeder order `%s`: use `sequential`, `circular` or `random`", options.Order)
+	}
+
+	file, err := os.Open(options.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var fields []string
+	var rows []map[string]string
+	switch filepath.Ext(options.Path) {
+	case ".csv":
+		fields, rows, err = readCSVRows(file)
+	case ".jsonl", ".ndjson", ".json":
+		fields, rows, err = readJSONRows(file)
+	default:
+		return nil, fmt.Errorf("unknown feeder format of `%s`: use a .csv or .jsonl file", options.Path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read `%s`: %s", options.Path, err.Error())
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("`%s` has no rows", options.Path)
+	}
+
+	if options.Unique && len(rows) < options.Workers {
+		return nil, fmt.Errorf("`%s` has fewer rows (%d) than workers (%d) to share them", options.Path, len(rows), options.Workers)
+	}
+
+	return &Feeder{
+		options: options,
+		fields:  fields,
+		rows:    rows,
+		next:    map[int]int{},
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Fields returns the names of the fields of the rows, which can be used as
+// placeholders in the commands.
+func (f *Feeder) Fields() []string {
+	return f.fields
+}
+
+// Before replaces the placeholders with the fields of the next row of the
+// worker. When there are no rows left the run fails.
+func (f *Feeder) Before(cmd *exec.Cmd, stats *RunStats) {
+	row, ok := f.pick(stats.Worker)
+	if !ok {
+		cmd.Err = errors.New("the feeder ran out of rows")
+		return
+	}
+
+	stats.Row = row + 1
+	replacePlaceholders(cmd, f.rows[row])
+}
+
+// After does nothing, as the row was already recorded.
+func (f *Feeder) After(_ *exec.Cmd, _ *RunStats) {}
+
+// pick returns the index of the next row of the worker.
+func (f *Feeder) pick(worker int) (int, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// Without Unique all the workers share a single partition with every row.
+	partition, partitions := 0, 1
+	if f.options.Unique && f.options.Workers > 1 {
+		partition, partitions = (worker-1)%f.options.Workers, f.options.Workers
+	}
+
+	size := len(f.rows) / partitions
+	if partition < len(f.rows)%partitions {
+		size++
+	}
+
+	var position int
+	switch f.options.Order {
+	case "random":
+		position = f.random.Intn(size)
+	case "circular":
+		position = f.next[partition] % size
+		f.next[partition]++
+	default:
+		position = f.next[partition]
+		if position >= size {
+			return 0, false
+		}
+		f.next[partition]++
+	}
+
+	return position*partitions + partition, true
+}
+
+// replacePlaceholders replaces the {{name}} placeholders in the arguments of the
+// command with the given values, leaving the placeholders of other names. If the
+// program itself changes it's looked up again.
+func replacePlaceholders(cmd *exec.Cmd, values map[string]string) {
+	if len(cmd.Args) == 0 {
+		return
+	}
+
+	program := cmd.Args[0]
+	for i, arg := range cmd.Args {
+		cmd.Args[i] = placeholderPattern.ReplaceAllStringFunc(arg, func(placeholder string) string {
+			if value, ok := values[placeholderPattern.FindStringSubmatch(placeholder)[1]]; ok {
+				return value
+			}
+			return placeholder
+		})
+	}
+
+	if cmd.Args[0] != program {
+		path, err := exec.LookPath(cmd.Args[0])
+		if err != nil {
+			cmd.Err = err
+			return
+		}
+		cmd.Path = path
+	}
+}
+
+func readCSVRows(r io.Reader) ([]string, []map[string]string, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil, errors.New("missing the header")
+	}
+
+	fields := records[0]
+	rows := []map[string]string{}
+	for _, record := range records[1:] {
+		row := map[string]string{}
+		for i, field := range fields {
+			row[field] = record[i]
+		}
+		rows = append(rows, row)
+	}
+
+	return fields, rows, nil
+}
+
+// readJSONRows reads an object per line. Strings are used as they are, and any
+// other value as JSON. The fields missing from a line are empty.
+func readJSONRows(r io.Reader) ([]string, []map[string]string, error) {
+	seen := map[string]bool{}
+	rows := []map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(scanner.Bytes(), &object); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %s", line, err.Error())
+		}
+
+		row := map[string]string{}
+		for field, raw := range object {
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				value = strings.TrimSpace(string(raw))
+			}
+			row[field] = value
+			seen[field] = true
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	fields := []string{}
+	for field := range seen {
+		fields = append(fields, field)
+		for _, row := range rows {
+			if _, ok := row[field]; !ok {
+				row[field] = ""
+			}
+		}
+	}
+	sort.Strings(fields)
+
+	return fields, rows, nil
+}
@@ -0,0 +1,193 @@
+package runner_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/commandrunner/fake_command_runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("Feeder", func() {
+	var (
+		dir       string
+		path      string
+		options   runner.FeederOptions
+		cmdRunner *fake_command_runner.FakeCommandRunner
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "bender-feeder")
+		Expect(err).NotTo(HaveOccurred())
+
+		path = filepath.Join(dir, "rows.csv")
+		Expect(ioutil.WriteFile(path, []byte("query,id\n\"hello world\",1\nfoo,2\nbar,3\n"), 0644)).To(Succeed())
+
+		options = runner.FeederOptions{Path: path, Order: "sequential"}
+		cmdRunner = fake_command_runner.New()
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	run := func(count, concurrency int, command string) runner.Summary {
+		feeder, err := runner.NewFeeder(options)
+		Expect(err).NotTo(HaveOccurred())
+
+		countRunner := runner.NewCountRunnerWithCmdRunner(cmdRunner, count)
+		countRunner.AddHook(feeder)
+
+		summary, err := countRunner.Run(concurrency, make(chan bool), command)
+		Expect(err).NotTo(HaveOccurred())
+		return summary
+	}
+
+	It("replaces the placeholders with the fields of each row in order", func() {
+		summary := run(3, 1, "grep -e {{query}} --id={{id}} {{other}}")
+
+		executed := cmdRunner.ExecutedCommands()
+		Expect(executed).To(HaveLen(3))
+		Expect(executed[0].Args).To(Equal([]string{"grep", "-e", "hello world", "--id=1", "{{other}}"}))
+		Expect(executed[1].Args).To(Equal([]string{"grep", "-e", "foo", "--id=2", "{{other}}"}))
+		Expect(executed[2].Args).To(Equal([]string{"grep", "-e", "bar", "--id=3", "{{other}}"}))
+
+		for i, runStats := range summary.EachRun {
+			Expect(runStats.Row).To(Equal(i + 1))
+		}
+	})
+
+	It("returns the names of the fields", func() {
+		feeder, err := runner.NewFeeder(options)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(feeder.Fields()).To(Equal([]string{"query", "id"}))
+	})
+
+	Context("when the rows run out", func() {
+		It("fails the remaining runs", func() {
+			feeder, err := runner.NewFeeder(options)
+			Expect(err).NotTo(HaveOccurred())
+
+			countRunner := runner.NewCountRunner(5)
+			countRunner.AddHook(feeder)
+
+			summary, err := countRunner.Run(1, make(chan bool), "echo {{id}}")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.SuccessCounter).To(Equal(3))
+			Expect(summary.ErrorCounter).To(Equal(2))
+			Expect(summary.EachRun[3].Failed).To(BeTrue())
+			Expect(summary.EachRun[3].Row).To(BeZero())
+		})
+	})
+
+	Context("when the order is circular", func() {
+		BeforeEach(func() {
+			options.Order = "circular"
+		})
+
+		It("starts over after the last row", func() {
+			summary := run(5, 1, "echo {{id}}")
+
+			Expect(summary.ErrorCounter).To(BeZero())
+			rows := []int{}
+			for _, runStats := range summary.EachRun {
+				rows = append(rows, runStats.Row)
+			}
+			Expect(rows).To(Equal([]int{1, 2, 3, 1, 2}))
+		})
+	})
+
+	Context("when the order is random", func() {
+		BeforeEach(func() {
+			options.Order = "random"
+		})
+
+		It("picks any of the rows", func() {
+			summary := run(20, 2, "echo {{id}}")
+
+			Expect(summary.ErrorCounter).To(BeZero())
+			for _, runStats := range summary.EachRun {
+				Expect(runStats.Row).To(BeElementOf(1, 2, 3))
+			}
+		})
+	})
+
+	Context("when the rows are unique per worker", func() {
+		BeforeEach(func() {
+			options.Order = "circular"
+			options.Unique = true
+			options.Workers = 2
+		})
+
+		It("gives each worker its own rows", func() {
+			summary := run(10, 2, "echo {{id}}")
+
+			Expect(summary.ErrorCounter).To(BeZero())
+			for _, runStats := range summary.EachRun {
+				Expect((runStats.Row - 1) % 2).To(Equal(runStats.Worker - 1))
+			}
+		})
+
+		It("returns an error when there are fewer rows than workers", func() {
+			options.Workers = 4
+			_, err := runner.NewFeeder(options)
+			Expect(err).To(MatchError("`" + path + "` has fewer rows (3) than workers (4) to share them"))
+		})
+	})
+
+	Context("when the file has JSON lines", func() {
+		BeforeEach(func() {
+			options.Path = filepath.Join(dir, "rows.jsonl")
+			Expect(ioutil.WriteFile(options.Path, []byte("{\"query\": \"a b\", \"limit\": 10}\n\n{\"query\": \"c\", \"tags\": [1, 2]}\n"), 0644)).To(Succeed())
+		})
+
+		It("uses the strings as they are and the other values as JSON", func() {
+			feeder, err := runner.NewFeeder(options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(feeder.Fields()).To(Equal([]string{"limit", "query", "tags"}))
+
+			run(2, 1, "echo {{query}} {{limit}} {{tags}}")
+
+			executed := cmdRunner.ExecutedCommands()
+			Expect(executed[0].Args).To(Equal([]string{"echo", "a b", "10", ""}))
+			Expect(executed[1].Args).To(Equal([]string{"echo", "c", "", "[1, 2]"}))
+		})
+
+		It("returns an error on invalid lines", func() {
+			Expect(ioutil.WriteFile(options.Path, []byte("{\"query\": \"a\"}\n{\n"), 0644)).To(Succeed())
+			_, err := runner.NewFeeder(options)
+			Expect(err).To(MatchError(HavePrefix("failed to read `" + options.Path + "`: line 2: ")))
+		})
+	})
+
+	Context("when the file is empty", func() {
+		It("returns an error", func() {
+			Expect(ioutil.WriteFile(path, []byte("query,id\n"), 0644)).To(Succeed())
+			_, err := runner.NewFeeder(options)
+			Expect(err).To(MatchError("`" + path + "` has no rows"))
+		})
+	})
+
+	Context("when the format is unknown", func() {
+		It("returns an error", func() {
+			options.Path = filepath.Join(dir, "rows.txt")
+			Expect(ioutil.WriteFile(options.Path, []byte("a\n"), 0644)).To(Succeed())
+			_, err := runner.NewFeeder(options)
+			Expect(err).To(MatchError("unknown feeder format of `" + options.Path + "`: use a .csv or .jsonl file"))
+		})
+	})
+
+	Context("when the order is invalid", func() {
+		It("returns an error", func() {
+			options.Order = "shuffle"
+			_, err := runner.NewFeeder(options)
+			Expect(err).To(MatchError("invalid feeder order `shuffle`: use `sequential`, `circular` or `random`"))
+		})
+	})
+})
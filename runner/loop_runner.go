@@ -48,14 +48,16 @@ func (r *LoopRunner) Run(concurrency int, cancel chan bool, commands ...string)
 	}
 
 	start := time.Now()
+	summary.StartTime = start
 	wg := sync.WaitGroup{}
 
 	stats := make(chan RunStats, 1000)
 
 	wg.Add(concurrency)
 	for i := 0; i < concurrency; i++ {
+		worker := i + 1
 		go func() {
-			r.startWorker(r.interval, cancel, stats, commands)
+			r.startWorker(worker, r.interval, cancel, stats, commands)
 			wg.Done()
 		}()
 	}
@@ -73,13 +75,13 @@ func (r *LoopRunner) Run(concurrency int, cancel chan bool, commands ...string)
 	return summary, nil
 }
 
-func (r *LoopRunner) startWorker(interval time.Duration, stop chan bool, stats chan RunStats, commands []string) {
-	for {
+func (r *LoopRunner) startWorker(worker int, interval time.Duration, stop chan bool, stats chan RunStats, commands []string) {
+	for iteration := 1; ; iteration++ {
 		select {
 		case <-stop:
 			return
 		default:
-			stats <- r.run(commands)
+			stats <- r.run(commands, worker, iteration)
 
 			select {
 			case <-stop:
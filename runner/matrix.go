@@ -0,0 +1,188 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Matches the valid names of the parameters.
+	parameterNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+	// Matches the {{name}} placeholders of the commands.
+	placeholderPattern = regexp.MustCompile(`{{\s*([A-Za-z_][A-Za-z0-9_-]*)\s*}}`)
+)
+
+// Defines a parameter of the matrix
+// - Name is the name of the parameter, used in the commands as {{name}}
+// - Values are the values the parameter takes, in order
+type Parameter struct {
+	Name   string
+	Values []string
+}
+
+// Defines one of the commands the matrix expands into
+// - Exec is the command, with the placeholders replaced by the values of the parameters
+// - Params are the values of the parameters the command uses, by name
+type MatrixCommand struct {
+	Exec   string
+	Params map[string]string
+}
+
+// ParseParameter parses a parameter as name=values, where the values are either
+// a list (e.g. threads=1,2,4 or algo=a,b) or a numeric range with an optional
+// step (e.g. threads=1..8 or threads=1..8:2).
+func ParseParameter(definition string) (Parameter, error) {
+	parts := strings.SplitN(definition, "=", 2)
+	if len(parts) != 2 || !parameterNamePattern.MatchString(parts[0]) || parts[1] == "" {
+		return Parameter{}, fmt.Errorf("invalid parameter `%s`: expected `name=values`, e.g. `threads=1,2,4` or `threads=1..8:2`", definition)
+	}
+
+	parameter := Parameter{Name: parts[0]}
+	if !strings.Contains(parts[1], "..") {
+		parameter.Values = strings.Split(parts[1], ",")
+		return parameter, nil
+	}
+
+	values, err := parseRange(parts[1])
+	if err != nil {
+		return Parameter{}, fmt.Errorf("invalid range `%s` of parameter `%s`: expected `start..end` or `start..end:step`, e.g. `1..8:2`", parts[1], parameter.Name)
+	}
+	parameter.Values = values
+
+	return parameter, nil
+}
+
+// parseRange returns the integers from start to end (inclusive) of a range
+// such as 1..8 or 1..8:2.
+func parseRange(definition string) ([]string, error) {
+	step := 1
+	bounds := definition
+	if i := strings.Index(definition, ":"); i >= 0 {
+		var err error
+		step, err = strconv.Atoi(definition[i+1:])
+		if err != nil || step <= 0 {
+			return nil, errors.New("invalid step")
+		}
+		bounds = definition[:i]
+	}
+
+	parts := strings.SplitN(bounds, "..", 2)
+	start, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	end, err := strconv.Atoi(parts[1])
+	if err != nil || end < start {
+		return nil, errors.New("invalid end")
+	}
+
+	values := []string{}
+	for value := start; value <= end; value += step {
+		values = append(values, strconv.Itoa(value))
+	}
+
+	return values, nil
+}
+
+// ExpandMatrix expands each command with {{name}} placeholders into one command
+// for each combination of the values of the parameters it uses. The combinations
+// follow the order of the parameters, the first one changing the slowest.
+// Commands without placeholders are kept as they are, and so are the placeholders
+// of the fields and the variables, which are replaced when the commands run (see
+// Feeder and Variables). Without parameters or fields, the other placeholders are
+// kept as they are too, as they can be part of the command, e.g. Go templates.
+func ExpandMatrix(commands []string, parameters []Parameter, fields []string) ([]MatrixCommand, error) {
+	byName := map[string]Parameter{}
+	for _, parameter := range parameters {
+		if _, ok := byName[parameter.Name]; ok {
+			return nil, fmt.Errorf("parameter `%s` is defined more than once", parameter.Name)
+		}
+		byName[parameter.Name] = parameter
+	}
+
+	runtime := map[string]bool{}
+	for _, variable := range Variables {
+		if _, ok := byName[variable]; ok {
+			return nil, fmt.Errorf("parameter `%s` has the name of a variable", variable)
+		}
+		runtime[variable] = true
+	}
+	for _, field := range fields {
+		if runtime[field] {
+			return nil, fmt.Errorf("field `%s` has the name of a variable", field)
+		}
+		if _, ok := byName[field]; ok {
+			return nil, fmt.Errorf("parameter `%s` has the name of a field", field)
+		}
+		runtime[field] = true
+	}
+
+	used := map[string]bool{}
+	expanded := []MatrixCommand{}
+	for _, command := range commands {
+		names := map[string]bool{}
+		for _, match := range placeholderPattern.FindAllStringSubmatch(command, -1) {
+			if runtime[match[1]] {
+				continue
+			}
+			if _, ok := byName[match[1]]; !ok {
+				if len(parameters) == 0 && len(fields) == 0 {
+					continue
+				}
+				return nil, fmt.Errorf("command `%s` uses `{{%s}}`, which isn't a parameter, a field or a variable", command, match[1])
+			}
+			names[match[1]] = true
+			used[match[1]] = true
+		}
+
+		combinations := []map[string]string{{}}
+		for _, parameter := range parameters {
+			if !names[parameter.Name] {
+				continue
+			}
+
+			next := []map[string]string{}
+			for _, combination := range combinations {
+				for _, value := range parameter.Values {
+					params := map[string]string{parameter.Name: value}
+					for name, previous := range combination {
+						params[name] = previous
+					}
+					next = append(next, params)
+				}
+			}
+			combinations = next
+		}
+
+		for _, params := range combinations {
+			// The placeholders of the fields and variables lose their spaces, as the
+			// command is split into arguments by the spaces before they're replaced.
+			matrixCommand := MatrixCommand{Exec: placeholderPattern.ReplaceAllStringFunc(command, func(placeholder string) string {
+				name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+				if value, ok := params[name]; ok {
+					return value
+				}
+				if !runtime[name] {
+					return placeholder
+				}
+				return "{{" + name + "}}"
+			})}
+			if len(params) > 0 {
+				matrixCommand.Params = params
+			}
+			expanded = append(expanded, matrixCommand)
+		}
+	}
+
+	for _, parameter := range parameters {
+		if !used[parameter.Name] {
+			return nil, fmt.Errorf("parameter `%s` isn't used by any command", parameter.Name)
+		}
+	}
+
+	return expanded, nil
+}
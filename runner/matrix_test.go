@@ -0,0 +1,156 @@
+package runner_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("ParseParameter", func() {
+	It("parses a list of values", func() {
+		parameter, err := runner.ParseParameter("algo=a,b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parameter).To(Equal(runner.Parameter{Name: "algo", Values: []string{"a", "b"}}))
+	})
+
+	It("parses a range", func() {
+		parameter, err := runner.ParseParameter("threads=1..4")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parameter.Values).To(Equal([]string{"1", "2", "3", "4"}))
+	})
+
+	It("parses a range with a step", func() {
+		parameter, err := runner.ParseParameter("threads=-2..8:4")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parameter.Values).To(Equal([]string{"-2", "2", "6"}))
+	})
+
+	It("returns an error on invalid definitions", func() {
+		for _, definition := range []string{"threads", "=1", "1x=1", "threads="} {
+			_, err := runner.ParseParameter(definition)
+			Expect(err).To(MatchError("invalid parameter `" + definition + "`: expected `name=values`, e.g. `threads=1,2,4` or `threads=1..8:2`"))
+		}
+	})
+
+	It("returns an error on invalid ranges", func() {
+		for _, values := range []string{"1..", "a..b", "4..1", "1..8:0", "1..8:x"} {
+			_, err := runner.ParseParameter("threads=" + values)
+			Expect(err).To(MatchError("invalid range `" + values + "` of parameter `threads`: expected `start..end` or `start..end:step`, e.g. `1..8:2`"))
+		}
+	})
+})
+
+var _ = Describe("ExpandMatrix", func() {
+	var parameters []runner.Parameter
+
+	BeforeEach(func() {
+		parameters = []runner.Parameter{
+			{Name: "threads", Values: []string{"1", "2"}},
+			{Name: "algo", Values: []string{"a", "b"}},
+		}
+	})
+
+	It("expands the commands into every combination of the parameters they use", func() {
+		commands, err := runner.ExpandMatrix([]string{"tool --algo {{algo}} --threads {{ threads }}", "ls", "echo {{algo}}"}, parameters, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(commands).To(Equal([]runner.MatrixCommand{
+			{Exec: "tool --algo a --threads 1", Params: map[string]string{"threads": "1", "algo": "a"}},
+			{Exec: "tool --algo b --threads 1", Params: map[string]string{"threads": "1", "algo": "b"}},
+			{Exec: "tool --algo a --threads 2", Params: map[string]string{"threads": "2", "algo": "a"}},
+			{Exec: "tool --algo b --threads 2", Params: map[string]string{"threads": "2", "algo": "b"}},
+			{Exec: "ls"},
+			{Exec: "echo a", Params: map[string]string{"algo": "a"}},
+			{Exec: "echo b", Params: map[string]string{"algo": "b"}},
+		}))
+	})
+
+	It("keeps the commands as they are without parameters", func() {
+		commands, err := runner.ExpandMatrix([]string{"ls", "sleep 1"}, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commands).To(Equal([]runner.MatrixCommand{{Exec: "ls"}, {Exec: "sleep 1"}}))
+	})
+
+	It("leaves the placeholders of the fields", func() {
+		commands, err := runner.ExpandMatrix([]string{"tool {{threads}} {{ query }}"}, parameters[:1], []string{"query"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commands).To(Equal([]runner.MatrixCommand{
+			{Exec: "tool 1 {{query}}", Params: map[string]string{"threads": "1"}},
+			{Exec: "tool 2 {{query}}", Params: map[string]string{"threads": "2"}},
+		}))
+	})
+
+	It("leaves the placeholders of the variables", func() {
+		commands, err := runner.ExpandMatrix([]string{"tool {{threads}} /tmp/{{ uuid }}"}, parameters[:1], nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commands).To(Equal([]runner.MatrixCommand{
+			{Exec: "tool 1 /tmp/{{uuid}}", Params: map[string]string{"threads": "1"}},
+			{Exec: "tool 2 /tmp/{{uuid}}", Params: map[string]string{"threads": "2"}},
+		}))
+	})
+
+	It("keeps the literal placeholders of the commands without parameters or fields", func() {
+		commands, err := runner.ExpandMatrix([]string{
+			"docker ps --format {{.Names}}",
+			"docker inspect --format {{range .Mounts}}{{.Source}}{{ end }}",
+		}, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commands).To(Equal([]runner.MatrixCommand{
+			{Exec: "docker ps --format {{.Names}}"},
+			{Exec: "docker inspect --format {{range .Mounts}}{{.Source}}{{ end }}"},
+		}))
+	})
+
+	It("keeps the literal placeholders that can't be names", func() {
+		commands, err := runner.ExpandMatrix([]string{"docker ps --format {{.Names}} --filter label={{threads}}"}, parameters[:1], nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commands).To(Equal([]runner.MatrixCommand{
+			{Exec: "docker ps --format {{.Names}} --filter label=1", Params: map[string]string{"threads": "1"}},
+			{Exec: "docker ps --format {{.Names}} --filter label=2", Params: map[string]string{"threads": "2"}},
+		}))
+	})
+
+	Context("when a parameter has the name of a variable", func() {
+		It("returns an error", func() {
+			parameters = append(parameters, runner.Parameter{Name: "worker", Values: []string{"1"}})
+			_, err := runner.ExpandMatrix([]string{"tool {{threads}} {{algo}} {{worker}}"}, parameters, nil)
+			Expect(err).To(MatchError("parameter `worker` has the name of a variable"))
+		})
+	})
+
+	Context("when a field has the name of a variable", func() {
+		It("returns an error", func() {
+			_, err := runner.ExpandMatrix([]string{"tool {{threads}} {{algo}}"}, parameters, []string{"iteration"})
+			Expect(err).To(MatchError("field `iteration` has the name of a variable"))
+		})
+	})
+
+	Context("when a parameter has the name of a field", func() {
+		It("returns an error", func() {
+			_, err := runner.ExpandMatrix([]string{"tool {{threads}} {{algo}}"}, parameters, []string{"algo"})
+			Expect(err).To(MatchError("parameter `algo` has the name of a field"))
+		})
+	})
+
+	Context("when a command uses an unknown placeholder", func() {
+		It("returns an error", func() {
+			_, err := runner.ExpandMatrix([]string{"tool {{threads}} {{algo}} {{size}}"}, parameters, nil)
+			Expect(err).To(MatchError("command `tool {{threads}} {{algo}} {{size}}` uses `{{size}}`, which isn't a parameter, a field or a variable"))
+		})
+	})
+
+	Context("when a parameter isn't used", func() {
+		It("returns an error", func() {
+			_, err := runner.ExpandMatrix([]string{"tool {{threads}}"}, parameters, nil)
+			Expect(err).To(MatchError("parameter `algo` isn't used by any command"))
+		})
+	})
+
+	Context("when a parameter is defined more than once", func() {
+		It("returns an error", func() {
+			parameters = append(parameters, runner.Parameter{Name: "algo", Values: []string{"c"}})
+			_, err := runner.ExpandMatrix([]string{"tool {{threads}} {{algo}}"}, parameters, nil)
+			Expect(err).To(MatchError("parameter `algo` is defined more than once"))
+		})
+	})
+})
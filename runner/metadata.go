@@ -0,0 +1,149 @@
+package runner
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Describes the environment a benchmark ran in, so results can be told apart
+// - Version is the version of bender
+// - GoVersion is the version of Go bender was built with
+// - Hostname is the name of the host
+// - OS is the operating system and architecture, e.g. linux/amd64
+// - Kernel is the release of the kernel (Linux only)
+// - CPUModel is the model name of the CPUs (Linux only)
+// - CPUCount is the number of logical CPUs
+// - MemoryTotal is the total memory of the host in bytes (Linux only)
+// - LoadAverageStart and LoadAverageEnd are the 1, 5 and 15 minutes load averages before and after the run (Linux only)
+// - Invocation is the full command line bender was invoked with
+// - GitCommit is the commit checked out in the working directory, if requested
+// - GitDirty signilizes if the working directory had uncommitted changes
+type Metadata struct {
+	Version          string    `json:"version"`
+	GoVersion        string    `json:"go_version"`
+	Hostname         string    `json:"hostname,omitempty"`
+	OS               string    `json:"os"`
+	Kernel           string    `json:"kernel,omitempty"`
+	CPUModel         string    `json:"cpu_model,omitempty"`
+	CPUCount         int       `json:"cpu_count"`
+	MemoryTotal      uint64    `json:"memory_total,omitempty"`
+	LoadAverageStart []float64 `json:"load_average_start,omitempty"`
+	LoadAverageEnd   []float64 `json:"load_average_end,omitempty"`
+	Invocation       []string  `json:"invocation"`
+	GitCommit        string    `json:"git_commit,omitempty"`
+	GitDirty         bool      `json:"git_dirty,omitempty"`
+}
+
+// CollectMetadata describes the current host, and the load average before the run.
+// The values that can't be read (e.g. /proc outside of Linux) are left empty.
+func CollectMetadata(version string, invocation []string) Metadata {
+	hostname, _ := os.Hostname()
+
+	return Metadata{
+		Version:          version,
+		GoVersion:        runtime.Version(),
+		Hostname:         hostname,
+		OS:               runtime.GOOS + "/" + runtime.GOARCH,
+		Kernel:           readKernel(),
+		CPUModel:         readCPUModel(),
+		CPUCount:         runtime.NumCPU(),
+		MemoryTotal:      readMemoryTotal(),
+		LoadAverageStart: LoadAverage(),
+		Invocation:       invocation,
+	}
+}
+
+// LoadAverage returns the 1, 5 and 15 minutes load averages of the host,
+// or nil if they can't be read.
+func LoadAverage() []float64 {
+	contents, err := ioutil.ReadFile("/proc/loadavg")
+	if err != nil {
+		return nil
+	}
+
+	fields := strings.Fields(string(contents))
+	if len(fields) < 3 {
+		return nil
+	}
+
+	load := make([]float64, 3)
+	for i := range load {
+		load[i], err = strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return nil
+		}
+	}
+
+	return load
+}
+
+// GitCommit returns the commit checked out in the directory, and whether it has
+// uncommitted changes.
+func GitCommit(dir string) (string, bool, error) {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = dir
+	commit, err := cmd.Output()
+	if err != nil {
+		return "", false, err
+	}
+
+	cmd = exec.Command("git", "status", "--porcelain")
+	cmd.Dir = dir
+	status, err := cmd.Output()
+	if err != nil {
+		return "", false, err
+	}
+
+	return strings.TrimSpace(string(commit)), len(strings.TrimSpace(string(status))) > 0, nil
+}
+
+func readKernel() string {
+	contents, err := ioutil.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(contents))
+}
+
+func readCPUModel() string {
+	return readProcField("/proc/cpuinfo", "model name")
+}
+
+// readMemoryTotal returns the MemTotal of /proc/meminfo in bytes.
+func readMemoryTotal() uint64 {
+	fields := strings.Fields(readProcField("/proc/meminfo", "MemTotal"))
+	if len(fields) == 0 {
+		return 0
+	}
+
+	kilobytes, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return kilobytes * 1024
+}
+
+// readProcField returns the value of the first `key: value` line of the file
+// with the given key.
+func readProcField(path, key string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+			return strings.TrimSpace(parts[1])
+		}
+	}
+
+	return ""
+}
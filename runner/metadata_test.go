@@ -0,0 +1,87 @@
+package runner_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("CollectMetadata", func() {
+	It("describes the host", func() {
+		metadata := runner.CollectMetadata("1.2.3", []string{"bender", "--count", "1"})
+
+		Expect(metadata.Version).To(Equal("1.2.3"))
+		Expect(metadata.GoVersion).To(Equal(runtime.Version()))
+		Expect(metadata.OS).To(Equal(runtime.GOOS + "/" + runtime.GOARCH))
+		Expect(metadata.CPUCount).To(Equal(runtime.NumCPU()))
+		Expect(metadata.Invocation).To(Equal([]string{"bender", "--count", "1"}))
+
+		hostname, err := os.Hostname()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(metadata.Hostname).To(Equal(hostname))
+	})
+
+	if runtime.GOOS == "linux" {
+		It("reads the kernel, CPU, memory and load average from /proc", func() {
+			metadata := runner.CollectMetadata("1.2.3", nil)
+
+			Expect(metadata.Kernel).NotTo(BeEmpty())
+			Expect(metadata.MemoryTotal).To(BeNumerically(">", 0))
+			Expect(metadata.LoadAverageStart).To(HaveLen(3))
+		})
+	}
+})
+
+var _ = Describe("GitCommit", func() {
+	var dir string
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=bender", "-c", "user.email=bender@example.com"}, args...)...)
+		cmd.Dir = dir
+		Expect(cmd.Run()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		if _, err := exec.LookPath("git"); err != nil {
+			Skip("git is not installed")
+		}
+
+		var err error
+		dir, err = ioutil.TempDir("", "bender-git")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("returns the commit checked out and whether there are changes", func() {
+		git("init", "-q")
+		Expect(ioutil.WriteFile(filepath.Join(dir, "file"), []byte("a"), 0644)).To(Succeed())
+		git("add", "file")
+		git("commit", "-q", "-m", "initial")
+
+		commit, dirty, err := runner.GitCommit(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commit).To(MatchRegexp("^[0-9a-f]{40}$"))
+		Expect(dirty).To(BeFalse())
+
+		Expect(ioutil.WriteFile(filepath.Join(dir, "file"), []byte("b"), 0644)).To(Succeed())
+		_, dirty, err = runner.GitCommit(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dirty).To(BeTrue())
+	})
+
+	Context("when the directory isn't a git repository", func() {
+		It("returns an error", func() {
+			_, _, err := runner.GitCommit(dir)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Defines the overhead of starting a command, measured by running a no-op command
+// - Command is the no-op command that was run
+// - Runs is the number of times it was run
+// - Statistics are the statistics of its durations
+// - Subtracted is true if the median overhead was subtracted from the statistics of the commands (see StatisticsOptions)
+type Overhead struct {
+	Command    string     `json:"command"`
+	Runs       int        `json:"runs"`
+	Statistics Statistics `json:"statistics"`
+	Subtracted bool       `json:"subtracted"`
+}
+
+// CalibrateOverhead measures the overhead of starting a command, running the
+// no-op command with the runner, which should be set up like the benchmark's.
+// All the runs of the no-op command must succeed.
+func CalibrateOverhead(r Runner, concurrency int, cancel chan bool, command string, options StatisticsOptions) (*Overhead, error) {
+	summary, err := r.Run(concurrency, cancel, command)
+	if err != nil {
+		return nil, err
+	}
+
+	if summary.ErrorCounter > 0 {
+		return nil, fmt.Errorf("%d of %d runs of `%s` failed", summary.ErrorCounter, len(summary.EachRun), command)
+	}
+
+	options.Overhead = 0
+	if err := CalculateStatistics(&summary, options); err != nil {
+		return nil, err
+	}
+
+	statistics := summary.Commands[1].Statistics
+	if statistics == nil {
+		return nil, errors.New("no calibration run finished")
+	}
+
+	return &Overhead{
+		Command:    command,
+		Runs:       len(summary.EachRun),
+		Statistics: *statistics,
+	}, nil
+}
+
+// subtractOverhead subtracts the overhead from the duration statistics, never
+// going below zero, and records the amount subtracted. The standard deviation
+// doesn't change, but the coefficient of variation is recalculated from the new
+// mean.
+func subtractOverhead(s *Statistics, amount time.Duration) {
+	for _, value := range []*time.Duration{
+		&s.Mean, &s.Median, &s.P90, &s.P95, &s.P99, &s.Min, &s.Max,
+		&s.MeanCI.Lower, &s.MeanCI.Upper, &s.MedianCI.Lower, &s.MedianCI.Upper,
+	} {
+		*value -= amount
+		if *value < 0 {
+			*value = 0
+		}
+	}
+
+	s.CV = 0
+	if s.Mean > 0 {
+		s.CV = float64(s.StdDev) / float64(s.Mean)
+	}
+	s.OverheadSubtracted = amount
+}
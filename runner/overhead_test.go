@@ -0,0 +1,42 @@
+package runner_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("Overhead", func() {
+	var options runner.StatisticsOptions
+
+	BeforeEach(func() {
+		options = runner.StatisticsOptions{
+			Method:     "bootstrap",
+			Confidence: 0.95,
+			Resamples:  100,
+			Seed:       1,
+		}
+	})
+
+	Describe("CalibrateOverhead", func() {
+		It("measures the durations of the no-op command", func() {
+			overhead, err := runner.CalibrateOverhead(runner.NewCountRunner(20), 2, make(chan bool), "true", options)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(overhead.Command).To(Equal("true"))
+			Expect(overhead.Runs).To(Equal(20))
+			Expect(overhead.Statistics.Median).To(BeNumerically(">", 0))
+			Expect(overhead.Statistics.Median).To(BeNumerically("<", 100*time.Millisecond))
+			Expect(overhead.Subtracted).To(BeFalse())
+		})
+
+		Context("when the no-op command fails", func() {
+			It("returns an error", func() {
+				_, err := runner.CalibrateOverhead(runner.NewCountRunner(3), 1, make(chan bool), "false", options)
+				Expect(err).To(MatchError("3 of 3 runs of `false` failed"))
+			})
+		})
+	})
+})
@@ -0,0 +1,319 @@
+package runner
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockTicks is the USER_HZ the CPU times of /proc/<pid>/stat are counted in,
+// which is 100 on every common Linux architecture.
+const clockTicks = 100
+
+// Defines the usage of the process tree of a run at a point in time
+// - Elapsed is the time since the run started
+// - CPU is the percentage of one CPU the tree used since the previous sample (it may be above 100)
+// - RSS is the resident memory of the tree in bytes
+// - Processes is the number of processes in the tree
+// - Threads is the number of threads of all the processes in the tree
+// - ReadBytes and WriteBytes are the bytes the live processes of the tree read from and wrote to storage so far
+// - VoluntaryCtxSwitches and InvoluntaryCtxSwitches are the context switches of the live processes of the tree so far
+type ProcessSample struct {
+	Elapsed                time.Duration `json:"elapsed"`
+	CPU                    float64       `json:"cpu"`
+	RSS                    uint64        `json:"rss"`
+	Processes              int           `json:"processes"`
+	Threads                int           `json:"threads"`
+	ReadBytes              uint64        `json:"read_bytes"`
+	WriteBytes             uint64        `json:"write_bytes"`
+	VoluntaryCtxSwitches   uint64        `json:"voluntary_ctx_switches"`
+	InvoluntaryCtxSwitches uint64        `json:"involuntary_ctx_switches"`
+}
+
+// Defines the usage of the process tree of a run
+// - PeakRSS is the highest resident memory of the tree in bytes
+// - PeakProcessRSS is the highest resident memory of any process of the tree in bytes (VmHWM), including between samples
+// - PeakProcesses is the highest number of processes in the tree
+// - PeakThreads is the highest number of threads in the tree
+// - VoluntaryCtxSwitches and InvoluntaryCtxSwitches are the context switches of all the processes of the tree, as last sampled
+// - UserTime and SystemTime are the CPU times of the process and its waited for descendants, once it exited
+// - Samples is the usage of the tree over time
+type ProcessStats struct {
+	PeakRSS                uint64          `json:"peak_rss"`
+	PeakProcessRSS         uint64          `json:"peak_process_rss"`
+	PeakProcesses          int             `json:"peak_processes"`
+	PeakThreads            int             `json:"peak_threads"`
+	VoluntaryCtxSwitches   uint64          `json:"voluntary_ctx_switches"`
+	InvoluntaryCtxSwitches uint64          `json:"involuntary_ctx_switches"`
+	UserTime               time.Duration   `json:"user_time"`
+	SystemTime             time.Duration   `json:"system_time"`
+	Samples                []ProcessSample `json:"samples"`
+}
+
+// ProcessSampler is a StartHook that samples the usage of the process tree of
+// every run from /proc/<pid> while it runs, and adds it to the RunStats.
+// Outside of Linux only the CPU times are recorded.
+type ProcessSampler struct {
+	interval time.Duration
+	procDir  string
+	mutex    sync.Mutex
+	running  map[*exec.Cmd]*processPoll
+}
+
+type processPoll struct {
+	stats    ProcessStats
+	switches map[int][2]uint64
+	stop     chan bool
+	done     chan bool
+}
+
+// procProcess is a process read from /proc.
+type procProcess struct {
+	pid         int
+	ppid        int
+	cpuTicks    uint64
+	threads     int
+	rss         uint64
+	readBytes   uint64
+	writeBytes  uint64
+	hwm         uint64
+	voluntary   uint64
+	involuntary uint64
+}
+
+// Creates a new instance of the ProcessSampler, taking a sample of every run
+// as soon as it starts, and then every interval.
+func NewProcessSampler(interval time.Duration) (*ProcessSampler, error) {
+	return NewProcessSamplerWithProcDir(interval, "/proc")
+}
+
+func NewProcessSamplerWithProcDir(interval time.Duration, procDir string) (*ProcessSampler, error) {
+	if interval <= 0 {
+		return nil, errors.New("sampling interval must be positive")
+	}
+
+	return &ProcessSampler{
+		interval: interval,
+		procDir:  procDir,
+		running:  map[*exec.Cmd]*processPoll{},
+	}, nil
+}
+
+// Before does nothing, as the process isn't running yet.
+func (s *ProcessSampler) Before(_ *exec.Cmd, _ *RunStats) {}
+
+// Started starts sampling the process tree in the background.
+func (s *ProcessSampler) Started(cmd *exec.Cmd, stats *RunStats) {
+	poll := &processPoll{
+		switches: map[int][2]uint64{},
+		stop:     make(chan bool),
+		done:     make(chan bool),
+	}
+
+	s.mutex.Lock()
+	s.running[cmd] = poll
+	s.mutex.Unlock()
+
+	go poll.sample(s.procDir, cmd.Process.Pid, stats.StartTime, s.interval)
+}
+
+// After stops sampling and adds the usage of the process tree to the stats.
+func (s *ProcessSampler) After(cmd *exec.Cmd, stats *RunStats) {
+	s.mutex.Lock()
+	poll, ok := s.running[cmd]
+	delete(s.running, cmd)
+	s.mutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	close(poll.stop)
+	<-poll.done
+
+	if cmd.ProcessState != nil {
+		poll.stats.UserTime = cmd.ProcessState.UserTime()
+		poll.stats.SystemTime = cmd.ProcessState.SystemTime()
+	}
+
+	stats.Process = &poll.stats
+}
+
+func (p *processPoll) sample(procDir string, pid int, start time.Time, interval time.Duration) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var previousTicks uint64
+	previousTime := start
+
+	for {
+		now := time.Now()
+		tree := readProcessTree(procDir, pid)
+		if len(tree) > 0 {
+			sample := ProcessSample{Elapsed: now.Sub(start), Processes: len(tree)}
+
+			var ticks uint64
+			for _, process := range tree {
+				ticks += process.cpuTicks
+				sample.RSS += process.rss
+				sample.Threads += process.threads
+				sample.ReadBytes += process.readBytes
+				sample.WriteBytes += process.writeBytes
+				sample.VoluntaryCtxSwitches += process.voluntary
+				sample.InvoluntaryCtxSwitches += process.involuntary
+
+				if process.hwm > p.stats.PeakProcessRSS {
+					p.stats.PeakProcessRSS = process.hwm
+				}
+				p.switches[process.pid] = [2]uint64{process.voluntary, process.involuntary}
+			}
+
+			// The context switches of the processes that exited are kept as they
+			// were last sampled.
+			p.stats.VoluntaryCtxSwitches, p.stats.InvoluntaryCtxSwitches = 0, 0
+			for _, switches := range p.switches {
+				p.stats.VoluntaryCtxSwitches += switches[0]
+				p.stats.InvoluntaryCtxSwitches += switches[1]
+			}
+
+			// The ticks of the processes that exited are gone from the sum.
+			if ticks > previousTicks && now.After(previousTime) {
+				sample.CPU = float64(ticks-previousTicks) / clockTicks / now.Sub(previousTime).Seconds() * 100
+			}
+			previousTicks, previousTime = ticks, now
+
+			p.stats.Samples = append(p.stats.Samples, sample)
+			if sample.RSS > p.stats.PeakRSS {
+				p.stats.PeakRSS = sample.RSS
+			}
+			if sample.Processes > p.stats.PeakProcesses {
+				p.stats.PeakProcesses = sample.Processes
+			}
+			if sample.Threads > p.stats.PeakThreads {
+				p.stats.PeakThreads = sample.Threads
+			}
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readProcessTree returns the process and all its live descendants, or nothing
+// if the process already exited. Only the stat of the other processes is read,
+// to find the tree, so sampling doesn't add more load than needed to the host.
+func readProcessTree(procDir string, root int) []procProcess {
+	entries, err := ioutil.ReadDir(procDir)
+	if err != nil {
+		return nil
+	}
+
+	processes := map[int]procProcess{}
+	children := map[int][]int{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		process, ok := readProcessStat(procDir, pid)
+		if !ok {
+			continue
+		}
+		processes[pid] = process
+		children[process.ppid] = append(children[process.ppid], pid)
+	}
+
+	if _, ok := processes[root]; !ok {
+		return nil
+	}
+
+	tree := []procProcess{}
+	pending := []int{root}
+	for len(pending) > 0 {
+		pid := pending[0]
+		pending = pending[1:]
+
+		tree = append(tree, readProcessUsage(procDir, processes[pid]))
+		pending = append(pending, children[pid]...)
+	}
+
+	return tree
+}
+
+// readProcessStat reads the parent, CPU time, threads and memory of the process
+// from /proc/<pid>/stat.
+func readProcessStat(procDir string, pid int) (procProcess, bool) {
+	contents, err := ioutil.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return procProcess{}, false
+	}
+
+	// The command name is in parentheses and may contain spaces, so the fields
+	// are taken after its closing parenthesis, starting with the state (3rd field).
+	stat := string(contents)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) < 22 {
+		return procProcess{}, false
+	}
+
+	process := procProcess{pid: pid}
+	process.ppid, _ = strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	process.cpuTicks = utime + stime
+	process.threads, _ = strconv.Atoi(fields[17])
+	rssPages, _ := strconv.ParseUint(fields[21], 10, 64)
+	process.rss = rssPages * uint64(os.Getpagesize())
+
+	return process, true
+}
+
+// readProcessUsage adds the IO, peak memory and context switches of the process
+// from /proc/<pid>/io and status, reading each file once.
+func readProcessUsage(procDir string, process procProcess) procProcess {
+	dir := filepath.Join(procDir, strconv.Itoa(process.pid))
+
+	io := readProcFields(dir + "/io")
+	process.readBytes, _ = strconv.ParseUint(io["read_bytes"], 10, 64)
+	process.writeBytes, _ = strconv.ParseUint(io["write_bytes"], 10, 64)
+
+	// VmHWM is in kB, e.g. `VmHWM:	    1234 kB`.
+	status := readProcFields(dir + "/status")
+	hwm, _ := strconv.ParseUint(strings.TrimSuffix(status["VmHWM"], " kB"), 10, 64)
+	process.hwm = hwm * 1024
+	process.voluntary, _ = strconv.ParseUint(status["voluntary_ctxt_switches"], 10, 64)
+	process.involuntary, _ = strconv.ParseUint(status["nonvoluntary_ctxt_switches"], 10, 64)
+
+	return process
+}
+
+// readProcFields returns the values of all the `key: value` lines of the file,
+// by key, or nothing if it can't be read.
+func readProcFields(path string) map[string]string {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	fields := map[string]string{}
+	for _, line := range strings.Split(string(contents), "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 {
+			fields[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,137 @@
+package runner_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("ProcessSampler", func() {
+	var (
+		dir     string
+		sampler *runner.ProcessSampler
+	)
+
+	BeforeEach(func() {
+		if runtime.GOOS != "linux" {
+			Skip("the process tree is only sampled on Linux")
+		}
+
+		var err error
+		dir, err = ioutil.TempDir("", "bender-process")
+		Expect(err).NotTo(HaveOccurred())
+
+		sampler, err = runner.NewProcessSampler(20 * time.Millisecond)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("samples the usage of the process tree of every run", func() {
+		script := filepath.Join(dir, "tree.sh")
+		Expect(ioutil.WriteFile(script, []byte("#!/bin/sh\nsleep 0.3 &\nsleep 0.3\nwait\n"), 0755)).To(Succeed())
+
+		countRunner := runner.NewCountRunner(2)
+		countRunner.AddHook(sampler)
+
+		summary, err := countRunner.Run(2, make(chan bool), script)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.EachRun).To(HaveLen(2))
+
+		for _, runStats := range summary.EachRun {
+			Expect(runStats.Failed).To(BeFalse())
+
+			process := runStats.Process
+			Expect(process).NotTo(BeNil())
+			Expect(process.PeakProcesses).To(Equal(3))
+			Expect(process.PeakThreads).To(BeNumerically(">=", 3))
+			Expect(process.PeakRSS).To(BeNumerically(">", 0))
+			Expect(len(process.Samples)).To(BeNumerically(">=", 5))
+
+			for i, sample := range process.Samples {
+				Expect(sample.RSS).To(BeNumerically("<=", process.PeakRSS))
+				Expect(sample.CPU).To(BeNumerically(">=", 0))
+				if i > 0 {
+					Expect(sample.Elapsed).To(BeNumerically(">", process.Samples[i-1].Elapsed))
+				}
+			}
+		}
+	})
+
+	Context("when reading a fixture proc directory", func() {
+		writeProcess := func(pid, ppid int, rssPages, hwm, voluntary, involuntary int) {
+			processDir := filepath.Join(dir, strconv.Itoa(pid))
+			Expect(os.MkdirAll(processDir, 0755)).To(Succeed())
+
+			// pid (comm) state ppid, then up to the rss (24th field) with utime,
+			// stime and the number of threads at the 14th, 15th and 20th fields.
+			stat := fmt.Sprintf("%d (fake cmd) S %d 0 0 0 0 0 0 0 0 0 10 5 0 0 0 0 1 0 0 0 %d\n", pid, ppid, rssPages)
+			Expect(ioutil.WriteFile(filepath.Join(processDir, "stat"), []byte(stat), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(processDir, "io"), []byte("read_bytes: 4096\nwrite_bytes: 8192\n"), 0644)).To(Succeed())
+
+			status := fmt.Sprintf("Name:\tfake\nVmHWM:\t    %d kB\nVmRSS:\t     4 kB\nvoluntary_ctxt_switches:\t%d\nnonvoluntary_ctxt_switches:\t%d\n", hwm, voluntary, involuntary)
+			Expect(ioutil.WriteFile(filepath.Join(processDir, "status"), []byte(status), 0644)).To(Succeed())
+		}
+
+		It("reads the peak memory and context switches from the status", func() {
+			cmd := exec.Command("sleep", "0.2")
+			Expect(cmd.Start()).To(Succeed())
+
+			pid := cmd.Process.Pid
+			writeProcess(pid, 1, 2, 100, 7, 3)
+			writeProcess(pid+1000000, pid, 1, 300, 5, 1)
+			writeProcess(pid+2000000, 1, 1, 900, 50, 50)
+
+			sampler, err := runner.NewProcessSamplerWithProcDir(10*time.Millisecond, dir)
+			Expect(err).NotTo(HaveOccurred())
+
+			stats := runner.RunStats{StartTime: time.Now()}
+			sampler.Started(cmd, &stats)
+			Expect(cmd.Wait()).To(Succeed())
+			sampler.After(cmd, &stats)
+
+			process := stats.Process
+			Expect(process).NotTo(BeNil())
+			Expect(process.PeakProcesses).To(Equal(2))
+			Expect(process.PeakRSS).To(Equal(uint64(3 * os.Getpagesize())))
+			Expect(process.PeakProcessRSS).To(Equal(uint64(300 * 1024)))
+			Expect(process.VoluntaryCtxSwitches).To(Equal(uint64(12)))
+			Expect(process.InvoluntaryCtxSwitches).To(Equal(uint64(4)))
+
+			sample := process.Samples[0]
+			Expect(sample.VoluntaryCtxSwitches).To(Equal(uint64(12)))
+			Expect(sample.InvoluntaryCtxSwitches).To(Equal(uint64(4)))
+			Expect(sample.ReadBytes).To(Equal(uint64(8192)))
+		})
+	})
+
+	Context("when the command can't start", func() {
+		It("doesn't add the usage", func() {
+			countRunner := runner.NewCountRunner(1)
+			countRunner.AddHook(sampler)
+
+			summary, err := countRunner.Run(1, make(chan bool), filepath.Join(dir, "missing"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.EachRun[0].Failed).To(BeTrue())
+			Expect(summary.EachRun[0].Process).To(BeNil())
+		})
+	})
+
+	Context("when the interval isn't positive", func() {
+		It("returns an error", func() {
+			_, err := runner.NewProcessSampler(0)
+			Expect(err).To(MatchError("sampling interval must be positive"))
+		})
+	})
+})
@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/commandrunner"
+	"code.cloudfoundry.org/commandrunner/linux_command_runner"
+)
+
+// RateRunner defines a runner that starts commands at a fixed arrival rate, for a
+// given duration. The concurrency level limits how many commands can be running
+// at the same time: when all workers are busy the arrival is dropped (and counted
+// in Summary.Dropped) instead of delaying the following ones.
+type RateRunner struct {
+	baseRunner
+	rate     float64
+	duration time.Duration
+}
+
+// Creates a new instance of the RateRunner.
+// Rate is the number of commands to start per second.
+func NewRateRunner(rate float64, duration time.Duration) *RateRunner {
+	cmdRunner := linux_command_runner.New()
+	return NewRateRunnerWithCmdRunner(cmdRunner, rate, duration)
+}
+
+func NewRateRunnerWithCmdRunner(cmdRunner commandrunner.CommandRunner, rate float64, duration time.Duration) *RateRunner {
+	baseRunner := newBaseRunner(cmdRunner)
+
+	return &RateRunner{
+		baseRunner: baseRunner,
+		rate:       rate,
+		duration:   duration,
+	}
+}
+
+// Start commands execution.
+// This method will block until the duration is over or `cancel` is closed. It will
+// then wait for any running command to finish and summarize the results.
+func (r *RateRunner) Run(concurrency int, cancel chan bool, commands ...string) (Summary, error) {
+	if len(commands) == 0 {
+		return Summary{}, errors.New("no commands given")
+	}
+
+	if r.rate <= 0 {
+		return Summary{}, errors.New("rate must be positive")
+	}
+
+	interval := arrivalInterval(r.rate)
+	if interval <= 0 {
+		return Summary{}, errors.New("rate is too high: the arrivals would be less than a nanosecond apart")
+	}
+
+	summary := Summary{
+		Commands: r.commandsSummary(commands),
+	}
+
+	start := time.Now()
+	summary.StartTime = start
+	wg := sync.WaitGroup{}
+
+	// A slot is taken for every arrival that starts, and freed once its run finishes.
+	slots := make(chan bool, concurrency)
+	tasks := make(chan bool, concurrency)
+	stats := make(chan RunStats, 1000)
+
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		worker := i + 1
+		go func() {
+			r.startWorker(worker, tasks, slots, stats, commands)
+			wg.Done()
+		}()
+	}
+
+	mergeStatsDone := make(chan bool)
+	go func() {
+		r.mergeRunstatsIntoSummary(stats, &summary)
+		close(mergeStatsDone)
+	}()
+
+arrivals:
+	for i := 0; ; i++ {
+		next := time.Duration(i) * interval
+		if next >= r.duration {
+			break
+		}
+
+		select {
+		case <-cancel:
+			break arrivals
+		case <-time.After(time.Until(start.Add(next))):
+		}
+
+		select {
+		case slots <- true:
+			tasks <- true
+		default:
+			summary.Dropped++
+		}
+	}
+
+	close(tasks)
+	wg.Wait()
+	summary.Duration = time.Since(start)
+	close(stats)
+	<-mergeStatsDone
+	return summary, nil
+}
+
+func (r *RateRunner) startWorker(worker int, tasks chan bool, slots chan bool, stats chan RunStats, commands []string) {
+	iteration := 0
+	for range tasks {
+		iteration++
+		stats <- r.run(commands, worker, iteration)
+		<-slots
+	}
+}
+
+// arrivalInterval returns the time between the arrivals at the given rate, which
+// is 0 when they would be less than a nanosecond apart.
+func arrivalInterval(rate float64) time.Duration {
+	return time.Duration(float64(time.Second) / rate)
+}
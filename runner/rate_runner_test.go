@@ -0,0 +1,135 @@
+package runner_test
+
+import (
+	"os/exec"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/commandrunner/fake_command_runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("RateRunner", func() {
+	var (
+		commands   []string
+		cancelChan chan bool
+		cmdRunner  *fake_command_runner.FakeCommandRunner
+		rateRunner *runner.RateRunner
+		rate       float64
+		duration   time.Duration
+
+		commandFunc func(cmd *exec.Cmd) error
+	)
+
+	BeforeEach(func() {
+		rate = 100
+		duration = 300 * time.Millisecond
+		cancelChan = make(chan bool)
+		cmdRunner = fake_command_runner.New()
+		commands = []string{"hello world"}
+		commandFunc = func(_ *exec.Cmd) error {
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}
+	})
+
+	JustBeforeEach(func() {
+		rateRunner = runner.NewRateRunnerWithCmdRunner(cmdRunner, rate, duration)
+
+		cmdRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: strings.Split(commands[0], " ")[0],
+			Args: strings.Split(commands[0], " ")[1:],
+		}, func(cmd *exec.Cmd) error {
+			return commandFunc(cmd)
+		})
+	})
+
+	Describe("Run", func() {
+		It("starts the commands at the given rate for the duration", func() {
+			summary, err := rateRunner.Run(4, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.Duration).To(BeNumerically("~", duration, 50*time.Millisecond))
+			Expect(summary.SuccessCounter).To(BeNumerically("~", 30, 3))
+			Expect(summary.Dropped).To(BeZero())
+		})
+
+		It("spaces the runs by the rate interval", func() {
+			summary, err := rateRunner.Run(4, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			first := summary.EachRun[0].StartTime
+			last := summary.EachRun[len(summary.EachRun)-1].StartTime
+			Expect(last.Sub(first)).To(BeNumerically("~", 290*time.Millisecond, 30*time.Millisecond))
+		})
+
+		Context("when all the workers are busy", func() {
+			BeforeEach(func() {
+				commandFunc = func(_ *exec.Cmd) error {
+					time.Sleep(50 * time.Millisecond)
+					return nil
+				}
+			})
+
+			It("drops the arrivals", func() {
+				summary, err := rateRunner.Run(1, cancelChan, commands...)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(summary.SuccessCounter).To(BeNumerically("~", 6, 1))
+				Expect(summary.Dropped).To(BeNumerically("~", 24, 3))
+			})
+		})
+
+		Context("when the rate is not positive", func() {
+			BeforeEach(func() {
+				rate = 0
+			})
+
+			It("returns an error", func() {
+				_, err := rateRunner.Run(1, cancelChan, commands...)
+				Expect(err).To(MatchError("rate must be positive"))
+			})
+		})
+
+		Context("when the arrivals would be less than a nanosecond apart", func() {
+			BeforeEach(func() {
+				rate = 2e9
+			})
+
+			It("returns an error", func() {
+				_, err := rateRunner.Run(1, cancelChan, commands...)
+				Expect(err).To(MatchError("rate is too high: the arrivals would be less than a nanosecond apart"))
+			})
+		})
+
+		Context("when there's no command given", func() {
+			It("returns an error", func() {
+				_, err := rateRunner.Run(1, cancelChan)
+				Expect(err).To(MatchError("no commands given"))
+			})
+		})
+
+		Context("cancelling", func() {
+			BeforeEach(func() {
+				duration = time.Hour
+			})
+
+			It("stops starting commands", func() {
+				finished := make(chan bool)
+				go func() {
+					defer GinkgoRecover()
+					_, err := rateRunner.Run(1, cancelChan, commands...)
+					Expect(err).NotTo(HaveOccurred())
+					close(finished)
+				}()
+
+				Consistently(finished, 100*time.Millisecond).ShouldNot(BeClosed())
+				close(cancelChan)
+				Eventually(finished).Should(BeClosed())
+			})
+		})
+	})
+})
@@ -3,6 +3,7 @@ package runner
 import (
 	"math/rand"
 	"os/exec"
+	"runtime"
 	"strings"
 	"time"
 
@@ -11,16 +12,40 @@ import (
 
 // Defines the summary of a Runner.Run
 // - Commands is the a indexed map of all the commands that were ran
+// - StartTime defines when the Run method started
 // - Duration is the total duration of the Run method
 // - SuccessCounter totalizes the total of times the commands were ran with success
 // - ErrorCounter totalizes the total of tiems the commands were ran with failure
 // - EachRun contains the information of each ran of the commands
+// - Dropped totalizes the arrivals that couldn't start because all workers were busy (RateRunner only)
+// - Warnings contains the reasons why the results might not be trustworthy (see CalculateStatistics)
+// - TimeSeries contains the runs bucketed by the time they finished (see BuildTimeSeries)
+// - Workers breaks down the runs by the worker that executed them (see SummarizeWorkers)
+// - Config contains the options the benchmark was run with, by name
+// - Metadata describes the environment the benchmark ran in (see CollectMetadata)
+// - System contains the usage of the host sampled during the run (see SystemSampler)
+// - Cgroup is the usage of the cgroup all the runs shared, if any (see Cgroup)
+// - Affinity contains the CPUs the runs were pinned to, if any (see Scheduler)
+// - Overhead is the overhead of starting a command, if it was calibrated (see CalibrateOverhead)
+// - Parameters are the names of the parameters of the matrix the commands were expanded from, in order (see ExpandMatrix)
 type Summary struct {
-	Commands       map[int]Command `json:"commands"`
-	Duration       time.Duration   `json:"duration"`
-	SuccessCounter int             `json:"success_counter"`
-	ErrorCounter   int             `json:"error_counter"`
-	EachRun        []RunStats      `json:"each_run"`
+	Commands       map[int]Command   `json:"commands"`
+	StartTime      time.Time         `json:"start_time"`
+	Duration       time.Duration     `json:"duration"`
+	SuccessCounter int               `json:"success_counter"`
+	ErrorCounter   int               `json:"error_counter"`
+	EachRun        []RunStats        `json:"each_run"`
+	Dropped        int               `json:"dropped,omitempty"`
+	Warnings       []string          `json:"warnings,omitempty"`
+	TimeSeries     []Bucket          `json:"time_series,omitempty"`
+	Workers        map[int]Worker    `json:"workers,omitempty"`
+	Config         map[string]string `json:"config,omitempty"`
+	Metadata       *Metadata         `json:"metadata,omitempty"`
+	System         []SystemSample    `json:"system,omitempty"`
+	Cgroup         *CgroupStats      `json:"cgroup,omitempty"`
+	Affinity       *Affinity         `json:"affinity,omitempty"`
+	Overhead       *Overhead         `json:"overhead,omitempty"`
+	Parameters     []string          `json:"parameters,omitempty"`
 }
 
 // Contains information about each of the times the commands were executed
@@ -28,19 +53,49 @@ type Summary struct {
 // - Duration is the duration of the command execution in this run
 // - StartTime defines when this run started
 // - Failed signilizes if the command returned any kind of error
+// - Stage is the profile stage this run started in (StagedRunner only)
+// - Concurrency is the number of active workers when this run started (StagedRunner only)
+// - Outlier signilizes if the duration was detected as an outlier (see CalculateStatistics)
+// - Worker is the index (starting at 1) of the worker that executed this run
+// - Iteration is the number (starting at 1) of this run within its worker
+// - ScheduleDelay is how long it took from the worker dequeuing the run until the process started
+// - ExitCode is the exit code of the process, or -1 if it failed without exiting normally
+// - Stderr is the end (up to 1KB) of the standard error of the process, if it failed
+// - Process is the usage of the process tree of the run (see ProcessSampler)
+// - Cgroup is the usage of the cgroup of the run, if it had its own (see Cgroup)
+// - Affinity is the CPUs the process was pinned to, if any (see Scheduler)
+// - Row is the number (starting at 1) of the row of the feeder used in the command, if any (see Feeder)
 type RunStats struct {
-	Command   int           `json:"command"`
-	Duration  time.Duration `json:"duration"`
-	StartTime time.Time     `json:"start_time"`
-	Failed    bool          `json:"failed"`
+	Command       int           `json:"command"`
+	Duration      time.Duration `json:"duration"`
+	StartTime     time.Time     `json:"start_time"`
+	Failed        bool          `json:"failed"`
+	Stage         int           `json:"stage,omitempty"`
+	Concurrency   int           `json:"concurrency,omitempty"`
+	Outlier       bool          `json:"outlier,omitempty"`
+	Worker        int           `json:"worker"`
+	Iteration     int           `json:"iteration"`
+	ScheduleDelay time.Duration `json:"schedule_delay"`
+	ExitCode      int           `json:"exit_code"`
+	Stderr        string        `json:"stderr,omitempty"`
+	Process       *ProcessStats `json:"process,omitempty"`
+	Cgroup        *CgroupStats  `json:"cgroup,omitempty"`
+	Affinity      []int         `json:"affinity,omitempty"`
+	Row           int           `json:"row,omitempty"`
 }
 
 // Simple command information
 // - Exec is the full command+args that were executed
 // - RunCount is the total times this particular command were executed
+// - Precision is the precision achieved for the command's statistic (AdaptiveRunner only)
+// - Statistics summarizes the durations of the command runs (see CalculateStatistics)
+// - Params are the values of the parameters of the matrix used in the command, by name (see ExpandMatrix)
 type Command struct {
-	Exec     string `json:"exec"`
-	RunCount int    `json:"run_count"`
+	Exec       string            `json:"exec"`
+	RunCount   int               `json:"run_count"`
+	Precision  *Precision        `json:"precision,omitempty"`
+	Statistics *Statistics       `json:"statistics,omitempty"`
+	Params     map[string]string `json:"params,omitempty"`
 }
 
 // Runner defines the interface for benchmarking a set of commands
@@ -48,12 +103,73 @@ type Command struct {
 // It also takes a list of commands to be ran, and return a Summary of the execution.
 // error is intended only to be returned if there's an error with the setup,
 // no with the command execution itself.
+// AddObserver registers an Observer to be notified of each run while it's running.
+// AddHook registers a Hook to be called around each run.
 type Runner interface {
 	Run(concurrency int, cancel chan bool, commands ...string) (Summary, error)
+	AddObserver(observer Observer)
+	AddHook(hook Hook)
+}
+
+// Observer is notified of every run as soon as it finishes, from the worker that
+// executed it. It must be safe for concurrent use.
+type Observer interface {
+	Observe(command string, stats RunStats)
+}
+
+// Hook is called around every run, from the worker that executes it.
+// Before is called right before the process starts, and may change the command,
+// e.g. its environment. After is called once the process finished, with the
+// complete stats of the run.
+// It must be safe for concurrent use.
+type Hook interface {
+	Before(cmd *exec.Cmd, stats *RunStats)
+	After(cmd *exec.Cmd, stats *RunStats)
+}
+
+// StartHook is a Hook that is also called as soon as the process started, e.g.
+// to inspect it while it runs. When any of the hooks is a StartHook, the runs
+// are started and waited for separately instead of being run.
+type StartHook interface {
+	Hook
+	Started(cmd *exec.Cmd, stats *RunStats)
+}
+
+// ThreadHook is a Hook that also prepares the OS thread the process is started
+// from, right before it starts. The process inherits the attributes of the
+// thread, e.g. its CPU affinity and priorities, so they apply from its very start.
+// Each run is started from a new thread, which exits once the process started,
+// so the attributes never leak to anything else. If PrepareThread returns an
+// error the process isn't started and the run fails.
+type ThreadHook interface {
+	Hook
+	PrepareThread(cmd *exec.Cmd, stats *RunStats) error
 }
 
 type baseRunner struct {
-	cmdRunner commandrunner.CommandRunner
+	cmdRunner   commandrunner.CommandRunner
+	observers   []Observer
+	hooks       []Hook
+	startHooks  []StartHook
+	threadHooks []ThreadHook
+}
+
+// AddObserver registers an observer to be notified of every run.
+// It must be called before Run.
+func (r *baseRunner) AddObserver(observer Observer) {
+	r.observers = append(r.observers, observer)
+}
+
+// AddHook registers a hook to be called around every run.
+// It must be called before Run.
+func (r *baseRunner) AddHook(hook Hook) {
+	r.hooks = append(r.hooks, hook)
+	if startHook, ok := hook.(StartHook); ok {
+		r.startHooks = append(r.startHooks, startHook)
+	}
+	if threadHook, ok := hook.(ThreadHook); ok {
+		r.threadHooks = append(r.threadHooks, threadHook)
+	}
 }
 
 func newBaseRunner(cmdRunner commandrunner.CommandRunner) baseRunner {
@@ -62,19 +178,46 @@ func newBaseRunner(cmdRunner commandrunner.CommandRunner) baseRunner {
 	}
 }
 
-func (r *baseRunner) run(commands []string) RunStats {
-	var runStats RunStats
-	runStats.StartTime = time.Now()
+// run picks one of the commands and runs it, on behalf of the given worker.
+// It must be called as soon as the worker dequeues the task, so the scheduling
+// delay can be measured.
+func (r *baseRunner) run(commands []string, worker, iteration int) RunStats {
+	return r.runWithStats(commands, RunStats{Worker: worker, Iteration: iteration})
+}
+
+// runWithStats runs one of the commands, starting from the given RunStats so
+// the hooks and observers see the fields the runner already set on it.
+func (r *baseRunner) runWithStats(commands []string, runStats RunStats) RunStats {
+	dequeued := time.Now()
 
 	cmdIdx := rand.Int() % len(commands)
 	args := strings.Split(commands[cmdIdx], " ")
 	runStats.Command = cmdIdx + 1
 
 	cmd := exec.Command(args[0], args[1:]...)
-	err := r.cmdRunner.Run(cmd)
+	stderr := &tailBuffer{limit: stderrLimit}
+	cmd.Stderr = stderr
+	setVariables(cmd, &runStats)
+	for _, hook := range r.hooks {
+		hook.Before(cmd, &runStats)
+	}
+
+	runStats.StartTime = time.Now()
+	runStats.ScheduleDelay = runStats.StartTime.Sub(dequeued)
+	err := r.execute(cmd, &runStats)
 	runStats.Duration = time.Since(runStats.StartTime)
 	if err != nil {
 		runStats.Failed = true
+		runStats.ExitCode = exitCode(err)
+		runStats.Stderr = string(stderr.data)
+	}
+
+	for _, hook := range r.hooks {
+		hook.After(cmd, &runStats)
+	}
+
+	for _, observer := range r.observers {
+		observer.Observe(commands[cmdIdx], runStats)
 	}
 
 	return runStats
@@ -82,17 +225,21 @@ func (r *baseRunner) run(commands []string) RunStats {
 
 func (r *baseRunner) mergeRunstatsIntoSummary(stats chan RunStats, summary *Summary) {
 	for runStats := range stats {
-		if runStats.Failed {
-			summary.ErrorCounter++
-		} else {
-			summary.SuccessCounter++
-		}
-		summary.EachRun = append(summary.EachRun, runStats)
+		r.mergeRunstats(runStats, summary)
+	}
+}
 
-		cmd := summary.Commands[runStats.Command]
-		cmd.RunCount++
-		summary.Commands[runStats.Command] = cmd
+func (r *baseRunner) mergeRunstats(runStats RunStats, summary *Summary) {
+	if runStats.Failed {
+		summary.ErrorCounter++
+	} else {
+		summary.SuccessCounter++
 	}
+	summary.EachRun = append(summary.EachRun, runStats)
+
+	cmd := summary.Commands[runStats.Command]
+	cmd.RunCount++
+	summary.Commands[runStats.Command] = cmd
 }
 
 func (r *baseRunner) commandsSummary(commands []string) map[int]Command {
@@ -106,3 +253,71 @@ func (r *baseRunner) commandsSummary(commands []string) map[int]Command {
 
 	return summary
 }
+
+// execute runs the command, calling the start hooks once it started, if any.
+func (r *baseRunner) execute(cmd *exec.Cmd, runStats *RunStats) error {
+	if len(r.startHooks) == 0 && len(r.threadHooks) == 0 {
+		return r.cmdRunner.Run(cmd)
+	}
+
+	if err := r.start(cmd, runStats); err != nil {
+		return err
+	}
+
+	for _, hook := range r.startHooks {
+		hook.Started(cmd, runStats)
+	}
+
+	return r.cmdRunner.Wait(cmd)
+}
+
+// start starts the command, from a thread prepared by the thread hooks if any.
+func (r *baseRunner) start(cmd *exec.Cmd, runStats *RunStats) error {
+	if len(r.threadHooks) == 0 {
+		return r.cmdRunner.Start(cmd)
+	}
+
+	started := make(chan error)
+	go func() {
+		// The thread is never unlocked, so it exits with the goroutine rather
+		// than running anything else with the attributes of the run.
+		runtime.LockOSThread()
+
+		for _, hook := range r.threadHooks {
+			if err := hook.PrepareThread(cmd, runStats); err != nil {
+				started <- err
+				return
+			}
+		}
+
+		started <- r.cmdRunner.Start(cmd)
+	}()
+
+	return <-started
+}
+
+// stderrLimit is how many bytes of the standard error of a failed run are kept.
+const stderrLimit = 1024
+
+// tailBuffer keeps the last `limit` bytes written to it.
+type tailBuffer struct {
+	limit int
+	data  []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = b.data[len(b.data)-b.limit:]
+	}
+	return len(p), nil
+}
+
+// exitCode returns the exit code of a failed run, or -1 if the process didn't
+// exit normally (e.g. it couldn't be started or was killed by a signal).
+func exitCode(err error) int {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
@@ -0,0 +1,236 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// IO scheduling classes of ioprio_set(2), by name.
+var ioClasses = map[string]int{
+	"realtime":    1,
+	"best-effort": 2,
+	"idle":        3,
+}
+
+// Defines how the processes of the runs are scheduled
+// - CPUs is the list of CPUs the runs are pinned to, round-robin. Empty means no pinning
+// - ByCommand pins the runs by their command (the Nth command to the Nth CPU), rather than by their worker
+// - Nice is the niceness of the processes, from -20 to 19. 0 leaves it unchanged
+// - IOPriority is the IO scheduling class and level of the processes, as class[:level], e.g. best-effort:7 or idle. Empty leaves it unchanged
+type SchedulingOptions struct {
+	CPUs       []int
+	ByCommand  bool
+	Nice       int
+	IOPriority string
+}
+
+// Defines the CPUs the runs were pinned to
+// - By is what the runs were pinned by: worker or command
+// - CPUs are the CPUs each worker or command was pinned to, by its index
+type Affinity struct {
+	By   string        `json:"by"`
+	CPUs map[int][]int `json:"cpus"`
+}
+
+// Scheduler is a ThreadHook that pins the process of every run to a CPU and sets
+// its nice and IO priority before it starts, so they apply to the whole run and
+// to the processes it forks. Only supported on Linux.
+type Scheduler struct {
+	options SchedulingOptions
+	ioprio  int
+
+	mutex    sync.Mutex
+	affinity Affinity
+	err      error
+}
+
+// Creates a new instance of the Scheduler, checking the CPUs can be used by
+// the processes.
+func NewScheduler(options SchedulingOptions) (*Scheduler, error) {
+	if !schedulingSupported {
+		return nil, errors.New("CPU affinity and scheduling priorities are only supported on Linux")
+	}
+
+	if options.Nice < -20 || options.Nice > 19 {
+		return nil, errors.New("the nice value must be between -20 and 19")
+	}
+
+	ioprio, err := parseIOPriority(options.IOPriority)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(options.CPUs) > 0 {
+		allowed, err := allowedCPUs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CPU affinity: %s", err.Error())
+		}
+
+		for _, cpu := range options.CPUs {
+			if !allowed[cpu] {
+				return nil, fmt.Errorf("CPU %d isn't available", cpu)
+			}
+		}
+	}
+
+	by := "worker"
+	if options.ByCommand {
+		by = "command"
+	}
+
+	return &Scheduler{
+		options:  options,
+		ioprio:   ioprio,
+		affinity: Affinity{By: by, CPUs: map[int][]int{}},
+	}, nil
+}
+
+// Before does nothing, as the process isn't running yet.
+func (s *Scheduler) Before(_ *exec.Cmd, _ *RunStats) {}
+
+// PrepareThread pins the thread the process is started from and sets its
+// priorities, which the process inherits. If they can't be applied the run
+// fails, rather than being measured without them.
+func (s *Scheduler) PrepareThread(_ *exec.Cmd, stats *RunStats) error {
+	var cpus []int
+	var index int
+	if len(s.options.CPUs) > 0 {
+		index = stats.Worker
+		if s.options.ByCommand {
+			index = stats.Command
+		}
+		cpus = []int{s.options.CPUs[(index-1)%len(s.options.CPUs)]}
+	}
+
+	err := s.apply(currentThread(), cpus)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err != nil {
+		if s.err == nil {
+			s.err = err
+		}
+		return err
+	}
+
+	if cpus != nil {
+		stats.Affinity = cpus
+		s.affinity.CPUs[index] = cpus
+	}
+	return nil
+}
+
+// After does nothing, as the settings were applied before the start.
+func (s *Scheduler) After(_ *exec.Cmd, _ *RunStats) {}
+
+// Affinity returns the CPUs the runs were pinned to, or nil if they weren't.
+func (s *Scheduler) Affinity() *Affinity {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.options.CPUs) == 0 {
+		return nil
+	}
+
+	affinity := Affinity{By: s.affinity.By, CPUs: map[int][]int{}}
+	for index, cpus := range s.affinity.CPUs {
+		affinity.CPUs[index] = cpus
+	}
+	return &affinity
+}
+
+// Err returns the first error applying the affinity or priorities to a run.
+func (s *Scheduler) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.err
+}
+
+func (s *Scheduler) apply(tid int, cpus []int) error {
+	if cpus != nil {
+		if err := setAffinity(tid, cpus); err != nil {
+			return fmt.Errorf("failed to set the CPU affinity: %s", err.Error())
+		}
+	}
+
+	if s.options.Nice != 0 {
+		if err := setNice(tid, s.options.Nice); err != nil {
+			return fmt.Errorf("failed to set the nice value: %s", err.Error())
+		}
+	}
+
+	if s.ioprio != 0 {
+		if err := setIOPriority(tid, s.ioprio); err != nil {
+			return fmt.Errorf("failed to set the IO priority: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// ParseCPUList parses a list of CPUs and ranges of CPUs, e.g. 0-3,6.
+func ParseCPUList(list string) ([]int, error) {
+	cpus := []int{}
+	for _, item := range strings.Split(list, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(item), "-", 2)
+
+		first, err := strconv.Atoi(bounds[0])
+		last := first
+		if err == nil && len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+		}
+		if err != nil || first < 0 || last < first {
+			return nil, fmt.Errorf("invalid CPU list `%s`: expected CPUs or ranges such as `0-3,6`", list)
+		}
+
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	return cpus, nil
+}
+
+// parseIOPriority returns the ioprio_set(2) value of the class[:level], or 0
+// if it's empty.
+func parseIOPriority(priority string) (int, error) {
+	if priority == "" {
+		return 0, nil
+	}
+
+	invalid := fmt.Errorf("invalid IO priority `%s`: use %s, with a level from 0 to 7 such as `best-effort:7`", priority, ioClassNames())
+
+	parts := strings.SplitN(priority, ":", 2)
+	class, ok := ioClasses[parts[0]]
+	if !ok {
+		return 0, invalid
+	}
+
+	level := 0
+	if len(parts) == 2 {
+		var err error
+		level, err = strconv.Atoi(parts[1])
+		if err != nil || level < 0 || level > 7 || parts[0] == "idle" {
+			return 0, invalid
+		}
+	}
+
+	return class<<13 | level, nil
+}
+
+func ioClassNames() string {
+	names := []string{}
+	for name := range ioClasses {
+		names = append(names, "`"+name+"`")
+	}
+	sort.Strings(names)
+
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
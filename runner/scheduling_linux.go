@@ -0,0 +1,67 @@
+//go:build linux
+// +build linux
+
+package runner
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const schedulingSupported = true
+
+// Maximum number of CPUs of the affinity masks.
+const maxCPUs = 1024
+
+// Who argument of ioprio_set(2) for a single process.
+const ioprioWhoProcess = 1
+
+type cpuMask [maxCPUs / 64]uint64
+
+// currentThread returns the ID of the calling thread, which the scheduling
+// syscalls apply to alone.
+func currentThread() int {
+	return syscall.Gettid()
+}
+
+func setAffinity(pid int, cpus []int) error {
+	var mask cpuMask
+	for _, cpu := range cpus {
+		mask[cpu/64] |= 1 << uint(cpu%64)
+	}
+
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, uintptr(pid), unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// allowedCPUs returns the CPUs bender, and so the commands, can run on.
+func allowedCPUs() (map[int]bool, error) {
+	var mask cpuMask
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, 0, unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+	if errno != 0 {
+		return nil, errno
+	}
+
+	allowed := map[int]bool{}
+	for cpu := 0; cpu < maxCPUs; cpu++ {
+		if mask[cpu/64]&(1<<uint(cpu%64)) != 0 {
+			allowed[cpu] = true
+		}
+	}
+	return allowed, nil
+}
+
+func setNice(pid, nice int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, pid, nice)
+}
+
+func setIOPriority(pid, ioprio int) error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(ioprio))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
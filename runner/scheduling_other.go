@@ -0,0 +1,30 @@
+//go:build !linux
+// +build !linux
+
+package runner
+
+import "errors"
+
+const schedulingSupported = false
+
+var errSchedulingUnsupported = errors.New("not supported on this platform")
+
+func currentThread() int {
+	return 0
+}
+
+func setAffinity(_ int, _ []int) error {
+	return errSchedulingUnsupported
+}
+
+func allowedCPUs() (map[int]bool, error) {
+	return nil, errSchedulingUnsupported
+}
+
+func setNice(_, _ int) error {
+	return errSchedulingUnsupported
+}
+
+func setIOPriority(_, _ int) error {
+	return errSchedulingUnsupported
+}
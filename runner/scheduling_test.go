@@ -0,0 +1,160 @@
+package runner_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("Scheduler", func() {
+	var dir string
+
+	BeforeEach(func() {
+		if runtime.GOOS != "linux" {
+			Skip("CPU affinity and scheduling priorities are only supported on Linux")
+		}
+
+		var err error
+		dir, err = ioutil.TempDir("", "bender-scheduling")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	// writeScript writes a script that records the affinity, nice value and IO
+	// priority its children inherited, as soon as it starts.
+	writeScript := func() string {
+		script := filepath.Join(dir, "script.sh")
+		out := filepath.Join(dir, "out")
+		contents := "#!/bin/sh\n" +
+			"grep Cpus_allowed_list /proc/self/status >> " + out + "\n" +
+			"echo nice $(cut -d ' ' -f 19 /proc/self/stat) >> " + out + "\n" +
+			"ionice -p $$ >> " + out + "\n"
+		Expect(ioutil.WriteFile(script, []byte(contents), 0755)).To(Succeed())
+		return script
+	}
+
+	readOutput := func() string {
+		contents, err := ioutil.ReadFile(filepath.Join(dir, "out"))
+		Expect(err).NotTo(HaveOccurred())
+		return string(contents)
+	}
+
+	It("pins the runs to the CPUs round-robin by worker and records the affinity", func() {
+		scheduler, err := runner.NewScheduler(runner.SchedulingOptions{CPUs: []int{0}})
+		Expect(err).NotTo(HaveOccurred())
+
+		countRunner := runner.NewCountRunner(2)
+		countRunner.AddHook(scheduler)
+
+		summary, err := countRunner.Run(2, make(chan bool), writeScript())
+		Expect(err).NotTo(HaveOccurred())
+		for _, runStats := range summary.EachRun {
+			Expect(runStats.Failed).To(BeFalse())
+			Expect(runStats.Affinity).To(Equal([]int{0}))
+		}
+
+		Expect(strings.Count(readOutput(), "Cpus_allowed_list:\t0\n")).To(Equal(2))
+		Expect(scheduler.Affinity()).To(Equal(&runner.Affinity{
+			By:   "worker",
+			CPUs: map[int][]int{1: {0}, 2: {0}},
+		}))
+		Expect(scheduler.Err()).NotTo(HaveOccurred())
+	})
+
+	It("pins the runs by command", func() {
+		scheduler, err := runner.NewScheduler(runner.SchedulingOptions{CPUs: []int{0}, ByCommand: true})
+		Expect(err).NotTo(HaveOccurred())
+
+		countRunner := runner.NewCountRunner(2)
+		countRunner.AddHook(scheduler)
+
+		_, err = countRunner.Run(2, make(chan bool), writeScript())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(scheduler.Affinity()).To(Equal(&runner.Affinity{
+			By:   "command",
+			CPUs: map[int][]int{1: {0}},
+		}))
+	})
+
+	It("sets the nice value and IO priority of the runs", func() {
+		scheduler, err := runner.NewScheduler(runner.SchedulingOptions{Nice: 5, IOPriority: "idle"})
+		Expect(err).NotTo(HaveOccurred())
+
+		countRunner := runner.NewCountRunner(1)
+		countRunner.AddHook(scheduler)
+
+		summary, err := countRunner.Run(1, make(chan bool), writeScript())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.EachRun[0].Failed).To(BeFalse())
+		Expect(summary.EachRun[0].Affinity).To(BeNil())
+
+		output := readOutput()
+		Expect(output).To(ContainSubstring("nice 5\n"))
+		Expect(output).To(ContainSubstring("idle"))
+		Expect(scheduler.Affinity()).To(BeNil())
+	})
+
+	Context("when the priorities can't be applied", func() {
+		It("fails the runs without starting them", func() {
+			if os.Geteuid() == 0 {
+				Skip("a negative nice value can be set with privileges")
+			}
+
+			scheduler, err := runner.NewScheduler(runner.SchedulingOptions{Nice: -20})
+			Expect(err).NotTo(HaveOccurred())
+
+			countRunner := runner.NewCountRunner(1)
+			countRunner.AddHook(scheduler)
+
+			summary, err := countRunner.Run(1, make(chan bool), writeScript())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.EachRun[0].Failed).To(BeTrue())
+			Expect(scheduler.Err()).To(MatchError(HavePrefix("failed to set the nice value: ")))
+			Expect(filepath.Join(dir, "out")).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Context("when a CPU isn't available", func() {
+		It("returns an error", func() {
+			_, err := runner.NewScheduler(runner.SchedulingOptions{CPUs: []int{0, 1000}})
+			Expect(err).To(MatchError("CPU 1000 isn't available"))
+		})
+	})
+
+	Context("when the priorities are invalid", func() {
+		It("returns an error", func() {
+			_, err := runner.NewScheduler(runner.SchedulingOptions{Nice: 20})
+			Expect(err).To(MatchError("the nice value must be between -20 and 19"))
+
+			for _, priority := range []string{"fast", "best-effort:8", "idle:1", "realtime:x"} {
+				_, err = runner.NewScheduler(runner.SchedulingOptions{IOPriority: priority})
+				Expect(err).To(MatchError("invalid IO priority `" + priority + "`: use `best-effort`, `idle` or `realtime`, with a level from 0 to 7 such as `best-effort:7`"))
+			}
+		})
+	})
+})
+
+var _ = Describe("ParseCPUList", func() {
+	It("parses CPUs and ranges", func() {
+		cpus, err := runner.ParseCPUList("0-3,6, 8")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cpus).To(Equal([]int{0, 1, 2, 3, 6, 8}))
+	})
+
+	It("returns an error on invalid lists", func() {
+		for _, list := range []string{"", "a", "3-1", "-1", "1,"} {
+			_, err := runner.ParseCPUList(list)
+			Expect(err).To(MatchError("invalid CPU list `" + list + "`: expected CPUs or ranges such as `0-3,6`"))
+		}
+	})
+})
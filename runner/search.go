@@ -0,0 +1,145 @@
+package runner
+
+import (
+	"errors"
+	"time"
+)
+
+// Defines the objectives a trial must meet to be considered sustainable
+// - P99 is the maximum p99 latency allowed. 0 means no latency objective
+// - MaxErrorRate is the maximum percentage (0-100) of failed runs allowed
+type SLO struct {
+	P99          time.Duration `json:"p99"`
+	MaxErrorRate float64       `json:"max_error_rate"`
+}
+
+// Defines the boundaries of a Search
+// - MinRate and MaxRate are the lowest and highest arrival rates (per second) to try
+// - Tolerance is how close (in runs per second) the highest passing and lowest failing rates must be to stop
+type SearchOptions struct {
+	MinRate   float64
+	MaxRate   float64
+	Tolerance float64
+}
+
+// Contains the results of a trial run of a Search
+// - Rate is the arrival rate (per second) used in the trial
+// - Passed is true if the trial met the SLO
+// - P99 is the p99 latency of the trial
+// - ErrorRate is the percentage (0-100) of failed runs in the trial
+// - Summary is the full summary of the trial
+type Trial struct {
+	Rate      float64       `json:"rate"`
+	Passed    bool          `json:"passed"`
+	P99       time.Duration `json:"p99"`
+	ErrorRate float64       `json:"error_rate"`
+	Summary   Summary       `json:"summary"`
+}
+
+// Defines the result of a Search
+// - MaxRate is the highest arrival rate that met the SLO. 0 if none did
+// - Trials contains all the trials, in the order they were run
+type SearchResult struct {
+	MaxRate float64 `json:"max_rate"`
+	Trials  []Trial `json:"trials"`
+}
+
+// Search binary-searches the highest arrival rate the commands can sustain while
+// meeting the SLO. `newRunner` must return the runner for a trial at the given rate,
+// usually a RateRunner with a short duration.
+// A trial fails if it breaks the SLO, if any arrival was dropped, or if no run finished.
+// If `cancel` is closed the search stops after the current trial.
+func Search(newRunner func(rate float64) Runner, slo SLO, options SearchOptions, concurrency int, cancel chan bool, commands ...string) (SearchResult, error) {
+	if options.MinRate <= 0 || options.MaxRate < options.MinRate {
+		return SearchResult{}, errors.New("invalid rate boundaries")
+	}
+
+	if arrivalInterval(options.MaxRate) <= 0 {
+		return SearchResult{}, errors.New("max rate is too high: the arrivals would be less than a nanosecond apart")
+	}
+
+	if options.Tolerance <= 0 {
+		return SearchResult{}, errors.New("tolerance must be positive")
+	}
+
+	result := SearchResult{}
+
+	trial := func(rate float64) (bool, error) {
+		summary, err := newRunner(rate).Run(concurrency, cancel, commands...)
+		if err != nil {
+			return false, err
+		}
+
+		t := newTrial(rate, summary, slo)
+		result.Trials = append(result.Trials, t)
+		return t.Passed, nil
+	}
+
+	cancelled := func() bool {
+		select {
+		case <-cancel:
+			return true
+		default:
+			return false
+		}
+	}
+
+	passed, err := trial(options.MinRate)
+	if err != nil || !passed {
+		return result, err
+	}
+	low := options.MinRate
+	result.MaxRate = low
+
+	if cancelled() {
+		return result, nil
+	}
+
+	passed, err = trial(options.MaxRate)
+	if err != nil {
+		return result, err
+	}
+	if passed {
+		result.MaxRate = options.MaxRate
+		return result, nil
+	}
+	high := options.MaxRate
+
+	for high-low > options.Tolerance && !cancelled() {
+		rate := (low + high) / 2
+
+		passed, err := trial(rate)
+		if err != nil {
+			return result, err
+		}
+
+		if passed {
+			low = rate
+		} else {
+			high = rate
+		}
+	}
+
+	result.MaxRate = low
+	return result, nil
+}
+
+func newTrial(rate float64, summary Summary, slo SLO) Trial {
+	t := Trial{
+		Rate:    rate,
+		P99:     percentile(sortedDurations(summary.EachRun), 99),
+		Summary: summary,
+	}
+
+	runs := len(summary.EachRun)
+	if runs > 0 {
+		t.ErrorRate = float64(summary.ErrorCounter) / float64(runs) * 100
+	}
+
+	t.Passed = runs > 0 &&
+		summary.Dropped == 0 &&
+		t.ErrorRate <= slo.MaxErrorRate &&
+		(slo.P99 == 0 || t.P99 <= slo.P99)
+
+	return t
+}
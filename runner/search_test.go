@@ -0,0 +1,137 @@
+package runner_test
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/commandrunner/fake_command_runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("Search", func() {
+	var (
+		commands   []string
+		cancelChan chan bool
+		cmdRunner  *fake_command_runner.FakeCommandRunner
+		slo        runner.SLO
+		options    runner.SearchOptions
+		newRunner  func(rate float64) runner.Runner
+
+		commandFunc func(cmd *exec.Cmd) error
+	)
+
+	BeforeEach(func() {
+		cancelChan = make(chan bool)
+		cmdRunner = fake_command_runner.New()
+		commands = []string{"hello world"}
+		commandFunc = func(_ *exec.Cmd) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		}
+
+		slo = runner.SLO{P99: 100 * time.Millisecond, MaxErrorRate: 0}
+		options = runner.SearchOptions{MinRate: 10, MaxRate: 200, Tolerance: 10}
+		newRunner = func(rate float64) runner.Runner {
+			return runner.NewRateRunnerWithCmdRunner(cmdRunner, rate, 200*time.Millisecond)
+		}
+
+		cmdRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: strings.Split(commands[0], " ")[0],
+			Args: strings.Split(commands[0], " ")[1:],
+		}, func(cmd *exec.Cmd) error {
+			return commandFunc(cmd)
+		})
+	})
+
+	It("finds the highest sustainable rate", func() {
+		// a single worker can't run more than 50 commands per second
+		result, err := runner.Search(newRunner, slo, options, 1, cancelChan, commands...)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(result.MaxRate).To(BeNumerically(">=", 25))
+		Expect(result.MaxRate).To(BeNumerically("<=", 55))
+	})
+
+	It("reports each trial", func() {
+		result, err := runner.Search(newRunner, slo, options, 1, cancelChan, commands...)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(len(result.Trials)).To(BeNumerically(">", 2))
+		Expect(result.Trials[0].Rate).To(Equal(10.0))
+		Expect(result.Trials[0].Passed).To(BeTrue())
+		Expect(result.Trials[0].Summary.SuccessCounter).To(BeNumerically(">", 0))
+		Expect(result.Trials[1].Rate).To(Equal(200.0))
+		Expect(result.Trials[1].Passed).To(BeFalse())
+		Expect(result.Trials[1].Summary.Dropped).To(BeNumerically(">", 0))
+	})
+
+	Context("when the highest rate is sustainable", func() {
+		It("stops after trying it", func() {
+			result, err := runner.Search(newRunner, slo, options, 10, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.MaxRate).To(Equal(200.0))
+			Expect(result.Trials).To(HaveLen(2))
+		})
+	})
+
+	Context("when no rate meets the SLO", func() {
+		BeforeEach(func() {
+			slo.P99 = 10 * time.Millisecond
+		})
+
+		It("reports a max rate of 0", func() {
+			result, err := runner.Search(newRunner, slo, options, 10, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.MaxRate).To(BeZero())
+			Expect(result.Trials).To(HaveLen(1))
+			Expect(result.Trials[0].P99).To(BeNumerically(">", 10*time.Millisecond))
+		})
+	})
+
+	Context("when the runs fail", func() {
+		BeforeEach(func() {
+			commandFunc = func(_ *exec.Cmd) error {
+				return errors.New("failed")
+			}
+		})
+
+		It("fails the trials above the error rate", func() {
+			result, err := runner.Search(newRunner, slo, options, 10, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.MaxRate).To(BeZero())
+			Expect(result.Trials[0].ErrorRate).To(Equal(100.0))
+		})
+	})
+
+	Context("when the boundaries are invalid", func() {
+		It("returns an error", func() {
+			options.MaxRate = 1
+			_, err := runner.Search(newRunner, slo, options, 1, cancelChan, commands...)
+			Expect(err).To(MatchError("invalid rate boundaries"))
+		})
+	})
+
+	Context("when the max rate is too high", func() {
+		It("returns an error", func() {
+			options.MaxRate = 2e9
+			_, err := runner.Search(newRunner, slo, options, 1, cancelChan, commands...)
+			Expect(err).To(MatchError("max rate is too high: the arrivals would be less than a nanosecond apart"))
+		})
+	})
+
+	Context("when the tolerance is not positive", func() {
+		It("returns an error", func() {
+			options.Tolerance = 0
+			_, err := runner.Search(newRunner, slo, options, 1, cancelChan, commands...)
+			Expect(err).To(MatchError("tolerance must be positive"))
+		})
+	})
+})
@@ -0,0 +1,257 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/commandrunner"
+	"code.cloudfoundry.org/commandrunner/linux_command_runner"
+)
+
+// How often the StagedRunner re-evaluates the number of active workers.
+const stagedRunnerResolution = 10 * time.Millisecond
+
+// Stage is a period of a load Profile
+// - Duration is how long the stage lasts
+// - Target is the concurrency level the stage ramps (linearly) to, from the previous stage's target
+type Stage struct {
+	Duration time.Duration `json:"duration"`
+	Target   int           `json:"target"`
+}
+
+// Profile is the ordered list of stages a StagedRunner goes through.
+type Profile []Stage
+
+// Parses a profile in the format `duration:target[,duration:target...]`,
+// e.g. "30s:1,2m:16,5m:16,1m:0".
+func ParseProfile(profile string) (Profile, error) {
+	var stages Profile
+
+	for _, def := range strings.Split(profile, ",") {
+		parts := strings.Split(strings.TrimSpace(def), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid stage `%s`: expected `duration:concurrency`", def)
+		}
+
+		duration, err := time.ParseDuration(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid stage `%s`: %s", def, err.Error())
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("invalid stage `%s`: duration must be positive", def)
+		}
+
+		target, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid stage `%s`: %s", def, err.Error())
+		}
+		if target < 0 {
+			return nil, fmt.Errorf("invalid stage `%s`: concurrency can't be negative", def)
+		}
+
+		stages = append(stages, Stage{Duration: duration, Target: target})
+	}
+
+	return stages, nil
+}
+
+// levelAt returns the stage (starting at 1) and concurrency level that should
+// be active `elapsed` time after the start, when starting from `initial`
+// concurrency. done is true once all the stages are over.
+func (p Profile) levelAt(initial int, elapsed time.Duration) (stage int, level int, done bool) {
+	from := initial
+
+	for i, s := range p {
+		if elapsed < s.Duration {
+			progress := float64(elapsed) / float64(s.Duration)
+			level = from + int(math.Round(float64(s.Target-from)*progress))
+			return i + 1, level, false
+		}
+
+		elapsed -= s.Duration
+		from = s.Target
+	}
+
+	return len(p), from, true
+}
+
+// MaxConcurrency returns the highest concurrency level of the profile, when
+// starting from `initial` concurrency.
+func (p Profile) MaxConcurrency(initial int) int {
+	max := initial
+	for _, s := range p {
+		if s.Target > max {
+			max = s.Target
+		}
+	}
+	return max
+}
+
+// StagedRunner defines a runner that follows a load Profile, adding and retiring
+// workers on the fly so the concurrency level matches the current stage.
+// Retired workers finish the command they are running before leaving.
+// Workers are identified by slots, from 1 to the highest concurrency of the
+// profile, which are reused once their previous worker left.
+// Each RunStats is tagged with the stage and the active concurrency at its start.
+type StagedRunner struct {
+	baseRunner
+	profile Profile
+
+	stateLock sync.Mutex
+	stage     int
+	active    int
+}
+
+// Creates a new instance of the StagedRunner.
+func NewStagedRunner(profile Profile) *StagedRunner {
+	cmdRunner := linux_command_runner.New()
+	return NewStagedRunnerWithCmdRunner(cmdRunner, profile)
+}
+
+func NewStagedRunnerWithCmdRunner(cmdRunner commandrunner.CommandRunner, profile Profile) *StagedRunner {
+	baseRunner := newBaseRunner(cmdRunner)
+
+	return &StagedRunner{
+		baseRunner: baseRunner,
+		profile:    profile,
+	}
+}
+
+// Start commands execution.
+// `concurrency` is the level the first stage ramps from.
+// This method will block until all the stages are over or `cancel` is closed.
+// It will then wait for any running command to finish and summarize the results.
+func (r *StagedRunner) Run(concurrency int, cancel chan bool, commands ...string) (Summary, error) {
+	if len(commands) == 0 {
+		return Summary{}, errors.New("no commands given")
+	}
+
+	if len(r.profile) == 0 {
+		return Summary{}, errors.New("no stages given")
+	}
+
+	summary := Summary{
+		Commands: r.commandsSummary(commands),
+	}
+
+	start := time.Now()
+	summary.StartTime = start
+	wg := sync.WaitGroup{}
+
+	stats := make(chan RunStats, 1000)
+
+	mergeStatsDone := make(chan bool)
+	go func() {
+		r.mergeRunstatsIntoSummary(stats, &summary)
+		close(mergeStatsDone)
+	}()
+
+	// A retired worker frees its slot only once its last run finished, so no
+	// two running workers share one, e.g. to share the rows of a Feeder. Until
+	// then a worker being added waits for a free slot.
+	workers := []chan bool{}
+	slots := make([]bool, r.profile.MaxConcurrency(concurrency))
+	slotsLock := sync.Mutex{}
+	ticker := time.NewTicker(stagedRunnerResolution)
+	defer ticker.Stop()
+
+stages:
+	for {
+		stage, level, done := r.profile.levelAt(concurrency, time.Since(start))
+		if done {
+			break
+		}
+
+		// The state is set before the workers are spawned, so their first runs
+		// are tagged with it too.
+		r.setState(stage, level)
+
+		for len(workers) < level {
+			slotsLock.Lock()
+			worker := 0
+			for i, busy := range slots {
+				if !busy {
+					slots[i] = true
+					worker = i + 1
+					break
+				}
+			}
+			slotsLock.Unlock()
+
+			if worker == 0 {
+				break
+			}
+
+			stop := make(chan bool)
+			workers = append(workers, stop)
+
+			wg.Add(1)
+			go func() {
+				r.startWorker(worker, stop, cancel, stats, commands)
+
+				slotsLock.Lock()
+				slots[worker-1] = false
+				slotsLock.Unlock()
+				wg.Done()
+			}()
+		}
+
+		for len(workers) > level {
+			close(workers[len(workers)-1])
+			workers = workers[:len(workers)-1]
+		}
+
+		select {
+		case <-cancel:
+			break stages
+		case <-ticker.C:
+		}
+	}
+
+	for _, stop := range workers {
+		close(stop)
+	}
+
+	wg.Wait()
+	summary.Duration = time.Since(start)
+	close(stats)
+	<-mergeStatsDone
+	return summary, nil
+}
+
+func (r *StagedRunner) setState(stage, active int) {
+	r.stateLock.Lock()
+	defer r.stateLock.Unlock()
+	r.stage = stage
+	r.active = active
+}
+
+func (r *StagedRunner) state() (stage, active int) {
+	r.stateLock.Lock()
+	defer r.stateLock.Unlock()
+	return r.stage, r.active
+}
+
+func (r *StagedRunner) startWorker(worker int, retire chan bool, stop chan bool, stats chan RunStats, commands []string) {
+	for iteration := 1; ; iteration++ {
+		select {
+		case <-retire:
+			return
+		case <-stop:
+			return
+		default:
+			stage, active := r.state()
+			stats <- r.runWithStats(commands, RunStats{
+				Worker:      worker,
+				Iteration:   iteration,
+				Stage:       stage,
+				Concurrency: active,
+			})
+		}
+	}
+}
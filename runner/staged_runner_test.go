@@ -0,0 +1,249 @@
+package runner_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/commandrunner/fake_command_runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("StagedRunner", func() {
+	var (
+		commands     []string
+		cancelChan   chan bool
+		cmdRunner    *fake_command_runner.FakeCommandRunner
+		stagedRunner *runner.StagedRunner
+		profile      runner.Profile
+
+		commandFunc func(cmd *exec.Cmd) error
+	)
+
+	BeforeEach(func() {
+		cancelChan = make(chan bool)
+		cmdRunner = fake_command_runner.New()
+		commands = []string{"hello world"}
+		commandFunc = func(_ *exec.Cmd) error {
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}
+
+		profile = runner.Profile{
+			{Duration: 100 * time.Millisecond, Target: 1},
+			{Duration: 100 * time.Millisecond, Target: 4},
+			{Duration: 200 * time.Millisecond, Target: 4},
+		}
+	})
+
+	JustBeforeEach(func() {
+		stagedRunner = runner.NewStagedRunnerWithCmdRunner(cmdRunner, profile)
+
+		cmdRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: strings.Split(commands[0], " ")[0],
+			Args: strings.Split(commands[0], " ")[1:],
+		}, func(cmd *exec.Cmd) error {
+			return commandFunc(cmd)
+		})
+	})
+
+	Describe("ParseProfile", func() {
+		It("parses all the stages", func() {
+			profile, err := runner.ParseProfile("30s:1,2m:16, 5m:16,1m:0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(profile).To(Equal(runner.Profile{
+				{Duration: 30 * time.Second, Target: 1},
+				{Duration: 2 * time.Minute, Target: 16},
+				{Duration: 5 * time.Minute, Target: 16},
+				{Duration: time.Minute, Target: 0},
+			}))
+		})
+
+		It("fails when a stage is malformed", func() {
+			_, err := runner.ParseProfile("30s:1,2m")
+			Expect(err).To(MatchError("invalid stage `2m`: expected `duration:concurrency`"))
+		})
+
+		It("fails when the duration is not positive", func() {
+			_, err := runner.ParseProfile("0s:1")
+			Expect(err).To(MatchError("invalid stage `0s:1`: duration must be positive"))
+		})
+
+		It("fails when the concurrency is negative", func() {
+			_, err := runner.ParseProfile("1s:-1")
+			Expect(err).To(MatchError("invalid stage `1s:-1`: concurrency can't be negative"))
+		})
+	})
+
+	Describe("Run", func() {
+		It("runs until all stages are over", func() {
+			summary, err := stagedRunner.Run(1, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary.Duration).To(BeNumerically("~", 400*time.Millisecond, 50*time.Millisecond))
+			Expect(summary.SuccessCounter).To(BeNumerically(">", 0))
+		})
+
+		It("tags each run with the stage and active concurrency", func() {
+			summary, err := stagedRunner.Run(1, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			maxConcurrency := map[int]int{}
+			for _, run := range summary.EachRun {
+				Expect(run.Stage).To(BeNumerically(">=", 1))
+				Expect(run.Stage).To(BeNumerically("<=", 3))
+				if run.Concurrency > maxConcurrency[run.Stage] {
+					maxConcurrency[run.Stage] = run.Concurrency
+				}
+			}
+
+			Expect(maxConcurrency[1]).To(Equal(1))
+			Expect(maxConcurrency[2]).To(BeNumerically(">", 1))
+			Expect(maxConcurrency[3]).To(Equal(4))
+		})
+
+		It("tags the runs before the hooks and observers see them", func() {
+			hook := &fakeHook{}
+			stagedRunner.AddHook(hook)
+			observer := &fakeObserver{}
+			stagedRunner.AddObserver(observer)
+
+			_, err := stagedRunner.Run(1, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(observer.stats).NotTo(BeEmpty())
+			for _, run := range observer.stats {
+				Expect(run.Stage).To(BeNumerically(">=", 1))
+				Expect(run.Concurrency).To(BeNumerically(">=", 1))
+			}
+			for _, run := range hook.after {
+				Expect(run.Stage).To(BeNumerically(">=", 1))
+			}
+		})
+
+		It("runs more commands as workers are added", func() {
+			summary, err := stagedRunner.Run(1, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			perStage := map[int]int{}
+			for _, run := range summary.EachRun {
+				perStage[run.Stage]++
+			}
+
+			Expect(perStage[3]).To(BeNumerically(">", 2*perStage[1]))
+		})
+
+		Context("when ramping down", func() {
+			BeforeEach(func() {
+				profile = runner.Profile{
+					{Duration: 100 * time.Millisecond, Target: 0},
+					{Duration: 100 * time.Millisecond, Target: 0},
+				}
+			})
+
+			It("retires the workers", func() {
+				summary, err := stagedRunner.Run(4, cancelChan, commands...)
+				Expect(err).NotTo(HaveOccurred())
+
+				for _, run := range summary.EachRun {
+					Expect(run.Stage).To(Equal(1))
+				}
+			})
+		})
+
+		Context("when ramping down and back up", func() {
+			var dir string
+
+			BeforeEach(func() {
+				var err error
+				dir, err = ioutil.TempDir("", "bender-staged")
+				Expect(err).NotTo(HaveOccurred())
+
+				profile = runner.Profile{
+					{Duration: 60 * time.Millisecond, Target: 1},
+					{Duration: 60 * time.Millisecond, Target: 3},
+					{Duration: 60 * time.Millisecond, Target: 3},
+				}
+			})
+
+			AfterEach(func() {
+				Expect(os.RemoveAll(dir)).To(Succeed())
+			})
+
+			It("reuses the slots of the retired workers", func() {
+				summary, err := stagedRunner.Run(3, cancelChan, commands...)
+				Expect(err).NotTo(HaveOccurred())
+
+				for _, run := range summary.EachRun {
+					Expect(run.Worker).To(BeElementOf(1, 2, 3))
+				}
+			})
+
+			It("never hands the unique rows of a feeder to two running workers", func() {
+				path := filepath.Join(dir, "rows.csv")
+				Expect(ioutil.WriteFile(path, []byte("id\n1\n2\n3\n"), 0644)).To(Succeed())
+				feeder, err := runner.NewFeeder(runner.FeederOptions{Path: path, Order: "circular", Unique: true, Workers: 3})
+				Expect(err).NotTo(HaveOccurred())
+				stagedRunner.AddHook(feeder)
+
+				summary, err := stagedRunner.Run(3, cancelChan, commands...)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(summary.ErrorCounter).To(BeZero())
+
+				for i, a := range summary.EachRun {
+					for _, b := range summary.EachRun[i+1:] {
+						if a.Row != b.Row {
+							continue
+						}
+						overlap := a.StartTime.Before(b.StartTime.Add(b.Duration)) && b.StartTime.Before(a.StartTime.Add(a.Duration))
+						Expect(overlap).To(BeFalse(), "row %d was used by workers %d and %d at once", a.Row, a.Worker, b.Worker)
+					}
+				}
+			})
+		})
+
+		Context("when there's no command given", func() {
+			It("returns an error", func() {
+				_, err := stagedRunner.Run(1, cancelChan)
+				Expect(err).To(MatchError("no commands given"))
+			})
+		})
+
+		Context("when there are no stages", func() {
+			BeforeEach(func() {
+				profile = runner.Profile{}
+			})
+
+			It("returns an error", func() {
+				_, err := stagedRunner.Run(1, cancelChan, commands...)
+				Expect(err).To(MatchError("no stages given"))
+			})
+		})
+
+		Context("cancelling", func() {
+			BeforeEach(func() {
+				profile = runner.Profile{{Duration: time.Hour, Target: 2}}
+			})
+
+			It("stops all the workers", func() {
+				finished := make(chan bool)
+				go func() {
+					defer GinkgoRecover()
+					_, err := stagedRunner.Run(1, cancelChan, commands...)
+					Expect(err).NotTo(HaveOccurred())
+					close(finished)
+				}()
+
+				Consistently(finished, 100*time.Millisecond).ShouldNot(BeClosed())
+				close(cancelChan)
+				Eventually(finished).Should(BeClosed())
+			})
+		})
+	})
+})
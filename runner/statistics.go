@@ -0,0 +1,451 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Sarle's bimodality coefficient above which a distribution is considered bimodal.
+const bimodalityThreshold = 5.0 / 9.0
+
+// Statistics of the durations of all the runs of a command
+// - Mean, Median, P90, P95, P99, Min, Max and StdDev summarize the durations
+// - MeanCI and MedianCI are the confidence intervals of the mean and median
+// - Outliers is the number of runs flagged as outliers
+// - OutliersExcluded is true if the outliers were left out of all the other statistics
+// - CV is the coefficient of variation (StdDev / Mean)
+// - Bimodality is Sarle's bimodality coefficient. Values above 5/9 suggest a bimodal distribution
+// - OverheadSubtracted is the overhead of starting a command that was subtracted from the durations, if any (see StatisticsOptions)
+type Statistics struct {
+	Mean               time.Duration `json:"mean"`
+	Median             time.Duration `json:"median"`
+	P90                time.Duration `json:"p90"`
+	P95                time.Duration `json:"p95"`
+	P99                time.Duration `json:"p99"`
+	Min                time.Duration `json:"min"`
+	Max                time.Duration `json:"max"`
+	StdDev             time.Duration `json:"std_dev"`
+	MeanCI             Interval      `json:"mean_ci"`
+	MedianCI           Interval      `json:"median_ci"`
+	Outliers           int           `json:"outliers"`
+	OutliersExcluded   bool          `json:"outliers_excluded,omitempty"`
+	CV                 float64       `json:"cv"`
+	Bimodality         float64       `json:"bimodality"`
+	OverheadSubtracted time.Duration `json:"overhead_subtracted,omitempty"`
+}
+
+// Interval is a confidence interval
+// - Lower and Upper are the bounds of the interval
+// - Confidence is the confidence level, e.g. 0.95
+type Interval struct {
+	Lower      time.Duration `json:"lower"`
+	Upper      time.Duration `json:"upper"`
+	Confidence float64       `json:"confidence"`
+}
+
+// Defines how the statistics are calculated
+// - Method is how confidence intervals are calculated: "bootstrap" or "analytic"
+// - Confidence is the confidence level of the intervals, e.g. 0.95
+// - Resamples is the number of resamples when using bootstrap
+// - Seed seeds the random resampling, so results can be reproduced
+// - Outliers is how outliers are detected: "iqr", "mad" or "none" (the default)
+// - ExcludeOutliers leaves the outliers out of the statistics
+// - CVThreshold is the coefficient of variation above which a warning is given. 0 disables it
+// - Overhead is subtracted from the statistics (but not from the durations of the runs) before the warnings are given, e.g. the median measured by CalibrateOverhead
+type StatisticsOptions struct {
+	Method          string
+	Confidence      float64
+	Resamples       int
+	Seed            int64
+	Outliers        string
+	ExcludeOutliers bool
+	CVThreshold     float64
+	Overhead        time.Duration
+}
+
+// Validate returns an error if the options can't be used to calculate statistics.
+func (o StatisticsOptions) Validate() error {
+	if o.Method != "bootstrap" && o.Method != "analytic" {
+		return errors.New("invalid method: use `bootstrap` or `analytic`")
+	}
+
+	if o.Confidence <= 0 || o.Confidence >= 1 {
+		return errors.New("confidence must be between 0 and 1")
+	}
+
+	if o.Method == "bootstrap" && o.Resamples < 1 {
+		return errors.New("resamples must be at least 1")
+	}
+
+	if o.Outliers != "" && o.Outliers != "none" && o.Outliers != "iqr" && o.Outliers != "mad" {
+		return errors.New("invalid outliers method: use `iqr`, `mad` or `none`")
+	}
+
+	return nil
+}
+
+// CalculateStatistics sets the Statistics of each of the summary's commands,
+// calculated from the durations in EachRun.
+// Runs detected as outliers are flagged in EachRun, and warnings are added to the
+// summary for commands whose results look noisy.
+// Commands that didn't run are left without statistics.
+func CalculateStatistics(summary *Summary, options StatisticsOptions) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	random := rand.New(rand.NewSource(options.Seed))
+	runs := map[int][]int{}
+	for i, run := range summary.EachRun {
+		runs[run.Command] = append(runs[run.Command], i)
+	}
+
+	// Iterate in order, so the random resamples don't depend on map ordering.
+	for i := 1; i <= len(summary.Commands); i++ {
+		cmd, ok := summary.Commands[i]
+		if !ok || len(runs[i]) == 0 {
+			continue
+		}
+
+		durations := make([]time.Duration, len(runs[i]))
+		for j, idx := range runs[i] {
+			durations[j] = summary.EachRun[idx].Duration
+		}
+		sort.Slice(durations, func(a, b int) bool { return durations[a] < durations[b] })
+
+		lower, upper := outlierFences(durations, options.Outliers)
+		included := []time.Duration{}
+		outliers := 0
+		for _, idx := range runs[i] {
+			run := &summary.EachRun[idx]
+			run.Outlier = run.Duration < lower || run.Duration > upper
+			if run.Outlier {
+				outliers++
+			} else {
+				included = append(included, run.Duration)
+			}
+		}
+
+		if options.ExcludeOutliers && outliers > 0 {
+			sort.Slice(included, func(a, b int) bool { return included[a] < included[b] })
+			durations = included
+		}
+
+		statistics := newStatistics(durations, options, random)
+		statistics.Outliers = outliers
+		statistics.OutliersExcluded = options.ExcludeOutliers && outliers > 0
+		if options.Overhead > 0 {
+			subtractOverhead(&statistics, options.Overhead)
+		}
+		cmd.Statistics = &statistics
+		summary.Commands[i] = cmd
+
+		summary.Warnings = append(summary.Warnings, noiseWarnings(i, cmd, options)...)
+	}
+
+	return nil
+}
+
+func newStatistics(sorted []time.Duration, options StatisticsOptions, random *rand.Rand) Statistics {
+	statistics := Statistics{
+		Mean:       mean(sorted),
+		Median:     percentile(sorted, 50),
+		P90:        percentile(sorted, 90),
+		P95:        percentile(sorted, 95),
+		P99:        percentile(sorted, 99),
+		Min:        sorted[0],
+		Max:        sorted[len(sorted)-1],
+		StdDev:     time.Duration(stdDev(sorted)),
+		MeanCI:     Interval{Confidence: options.Confidence},
+		MedianCI:   Interval{Confidence: options.Confidence},
+		Bimodality: bimodality(sorted),
+	}
+
+	if statistics.Mean > 0 {
+		statistics.CV = float64(statistics.StdDev) / float64(statistics.Mean)
+	}
+
+	if options.Method == "bootstrap" {
+		statistics.MeanCI.Lower, statistics.MeanCI.Upper,
+			statistics.MedianCI.Lower, statistics.MedianCI.Upper = bootstrapIntervals(sorted, options, random)
+	} else {
+		statistics.MeanCI.Lower, statistics.MeanCI.Upper = meanInterval(sorted, options.Confidence)
+		statistics.MedianCI.Lower, statistics.MedianCI.Upper, _ = percentileInterval(sorted, 50, options.Confidence)
+	}
+
+	return statistics
+}
+
+// noiseWarnings returns warnings about the command results being untrustworthy.
+func noiseWarnings(index int, cmd Command, options StatisticsOptions) []string {
+	warnings := []string{}
+	statistics := cmd.Statistics
+
+	if statistics.Outliers > 0 {
+		warnings = append(warnings, fmt.Sprintf("command %d (%s): %d outlier(s) detected", index, cmd.Exec, statistics.Outliers))
+	}
+
+	if options.CVThreshold > 0 && statistics.CV > options.CVThreshold {
+		warnings = append(warnings, fmt.Sprintf("command %d (%s): high coefficient of variation (%.2f), results may be unreliable", index, cmd.Exec, statistics.CV))
+	}
+
+	if statistics.Bimodality > bimodalityThreshold {
+		warnings = append(warnings, fmt.Sprintf("command %d (%s): durations look bimodal (coefficient %.2f), results may be unreliable", index, cmd.Exec, statistics.Bimodality))
+	}
+
+	return warnings
+}
+
+// outlierFences returns the bounds outside of which a duration is considered an
+// outlier, according to the method:
+// - iqr: Tukey's fences, 1.5 interquartile ranges away from the quartiles
+// - mad: modified z-score (based on the median absolute deviation) above 3.5
+func outlierFences(sorted []time.Duration, method string) (lower, upper time.Duration) {
+	lower, upper = time.Duration(math.MinInt64), time.Duration(math.MaxInt64)
+
+	switch method {
+	case "iqr":
+		q1 := percentile(sorted, 25)
+		q3 := percentile(sorted, 75)
+		iqr := float64(q3 - q1)
+		lower = q1 - time.Duration(1.5*iqr)
+		upper = q3 + time.Duration(1.5*iqr)
+
+	case "mad":
+		median := percentile(sorted, 50)
+		deviations := make([]time.Duration, len(sorted))
+		for i, d := range sorted {
+			deviations[i] = d - median
+			if deviations[i] < 0 {
+				deviations[i] = -deviations[i]
+			}
+		}
+		sort.Slice(deviations, func(a, b int) bool { return deviations[a] < deviations[b] })
+
+		mad := float64(percentile(deviations, 50))
+		if mad > 0 {
+			margin := time.Duration(3.5 * mad / 0.6745)
+			lower = median - margin
+			upper = median + margin
+		}
+	}
+
+	return lower, upper
+}
+
+// bimodality returns Sarle's bimodality coefficient of the durations, or 0 if
+// there are not enough of them.
+func bimodality(durations []time.Duration) float64 {
+	n := float64(len(durations))
+	if n < 4 {
+		return 0
+	}
+
+	avg := float64(mean(durations))
+	var m2, m3, m4 float64
+	for _, d := range durations {
+		diff := float64(d) - avg
+		m2 += diff * diff
+		m3 += diff * diff * diff
+		m4 += diff * diff * diff * diff
+	}
+	m2 /= n
+	m3 /= n
+	m4 /= n
+
+	if m2 == 0 {
+		return 0
+	}
+
+	// sample skewness and excess kurtosis, corrected for bias
+	g := math.Sqrt(n*(n-1)) / (n - 2) * m3 / math.Pow(m2, 1.5)
+	k := (n - 1) / ((n - 2) * (n - 3)) * ((n+1)*(m4/(m2*m2)-3) + 6)
+
+	return (g*g + 1) / (k + 3*(n-1)*(n-1)/((n-2)*(n-3)))
+}
+
+// bootstrapIntervals returns the percentile bootstrap confidence intervals of the
+// mean and the median of the durations.
+func bootstrapIntervals(durations []time.Duration, options StatisticsOptions, random *rand.Rand) (meanLower, meanUpper, medianLower, medianUpper time.Duration) {
+	means := make([]time.Duration, options.Resamples)
+	medians := make([]time.Duration, options.Resamples)
+	resample := make([]time.Duration, len(durations))
+
+	for i := 0; i < options.Resamples; i++ {
+		for j := range resample {
+			resample[j] = durations[random.Intn(len(durations))]
+		}
+		sort.Slice(resample, func(a, b int) bool { return resample[a] < resample[b] })
+
+		means[i] = mean(resample)
+		medians[i] = percentile(resample, 50)
+	}
+
+	sort.Slice(means, func(a, b int) bool { return means[a] < means[b] })
+	sort.Slice(medians, func(a, b int) bool { return medians[a] < medians[b] })
+
+	alpha := (1 - options.Confidence) / 2 * 100
+	return percentile(means, alpha), percentile(means, 100-alpha),
+		percentile(medians, alpha), percentile(medians, 100-alpha)
+}
+
+// sortedDurations returns the durations of the given runs in ascending order.
+func sortedDurations(runs []RunStats) []time.Duration {
+	durations := make([]time.Duration, len(runs))
+	for i, run := range runs {
+		durations[i] = run.Duration
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations
+}
+
+// percentile returns the p-th (0-100) percentile of the already sorted durations,
+// interpolating linearly between the closest ranks.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+
+	weight := rank - float64(lower)
+	return sorted[lower] + time.Duration(weight*float64(sorted[upper]-sorted[lower]))
+}
+
+// mean returns the average of the durations.
+func mean(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	return total / time.Duration(len(durations))
+}
+
+// stdDev returns the sample standard deviation of the durations.
+func stdDev(durations []time.Duration) float64 {
+	if len(durations) < 2 {
+		return 0
+	}
+
+	avg := float64(mean(durations))
+	var sum float64
+	for _, d := range durations {
+		sum += (float64(d) - avg) * (float64(d) - avg)
+	}
+	return math.Sqrt(sum / float64(len(durations)-1))
+}
+
+// normalQuantile returns the quantile function (inverse CDF) of the standard
+// normal distribution, using Acklam's rational approximation.
+func normalQuantile(p float64) float64 {
+	a := []float64{-3.969683028665376e+01, 2.209460984245205e+02, -2.759285104469687e+02, 1.383577518672690e+02, -3.066479806614716e+01, 2.506628277459239e+00}
+	b := []float64{-5.447609879822406e+01, 1.615858368580409e+02, -1.556989798598866e+02, 6.680131188771972e+01, -1.328068155288572e+01}
+	c := []float64{-7.784894002430293e-03, -3.223964580411365e-01, -2.400758277161838e+00, -2.549732539343734e+00, 4.374664141464968e+00, 2.938163982698783e+00}
+	d := []float64{7.784695709041462e-03, 3.224671290700398e-01, 2.445134137142996e+00, 3.754408661907416e+00}
+
+	const low = 0.02425
+
+	switch {
+	case p <= 0:
+		return math.Inf(-1)
+	case p >= 1:
+		return math.Inf(1)
+	case p < low:
+		q := math.Sqrt(-2 * math.Log(p))
+		return (((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) /
+			((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	case p > 1-low:
+		q := math.Sqrt(-2 * math.Log(1-p))
+		return -(((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) /
+			((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	default:
+		q := p - 0.5
+		r := q * q
+		return (((((a[0]*r+a[1])*r+a[2])*r+a[3])*r+a[4])*r + a[5]) * q /
+			(((((b[0]*r+b[1])*r+b[2])*r+b[3])*r+b[4])*r + 1)
+	}
+}
+
+// tQuantile returns the quantile function of the Student's t distribution with
+// `df` degrees of freedom, using the Cornish-Fisher expansion of the normal
+// quantile (Abramowitz & Stegun 26.7.5).
+func tQuantile(p float64, df int) float64 {
+	z := normalQuantile(p)
+	if df < 1 {
+		return z
+	}
+
+	v := float64(df)
+	z3 := z * z * z
+	z5 := z3 * z * z
+	z7 := z5 * z * z
+	z9 := z7 * z * z
+
+	g1 := (z3 + z) / 4
+	g2 := (5*z5 + 16*z3 + 3*z) / 96
+	g3 := (3*z7 + 19*z5 + 17*z3 - 15*z) / 384
+	g4 := (79*z9 + 776*z7 + 1482*z5 - 1920*z3 - 945*z) / 92160
+
+	return z + g1/v + g2/(v*v) + g3/(v*v*v) + g4/(v*v*v*v)
+}
+
+// meanInterval returns the confidence interval of the mean of the durations,
+// using the Student's t distribution.
+func meanInterval(durations []time.Duration, confidence float64) (lower, upper time.Duration) {
+	avg := mean(durations)
+	if len(durations) < 2 {
+		return avg, avg
+	}
+
+	t := tQuantile(1-(1-confidence)/2, len(durations)-1)
+	margin := time.Duration(t * stdDev(durations) / math.Sqrt(float64(len(durations))))
+	return avg - margin, avg + margin
+}
+
+// percentileInterval returns the distribution-free confidence interval of the
+// p-th (0-100) percentile of the already sorted durations, picking the order
+// statistics given by the normal approximation of the binomial distribution.
+// ok is false when there are not enough durations to reach the confidence level,
+// in which case the interval is clamped to the extremes.
+func percentileInterval(sorted []time.Duration, p float64, confidence float64) (lower, upper time.Duration, ok bool) {
+	n := float64(len(sorted))
+	if n == 0 {
+		return 0, 0, false
+	}
+
+	q := p / 100
+	z := normalQuantile(1 - (1-confidence)/2)
+	margin := z * math.Sqrt(n*q*(1-q))
+
+	lowerRank := int(math.Round(n*q-margin)) - 1
+	upperRank := int(math.Round(n*q+1+margin)) - 1
+	ok = true
+
+	if lowerRank < 0 {
+		lowerRank = 0
+		ok = false
+	}
+	if upperRank > len(sorted)-1 {
+		upperRank = len(sorted) - 1
+		ok = false
+	}
+	if upperRank < lowerRank {
+		upperRank = lowerRank
+	}
+
+	return sorted[lowerRank], sorted[upperRank], ok
+}
@@ -0,0 +1,281 @@
+package runner_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("CalculateStatistics", func() {
+	var (
+		summary runner.Summary
+		options runner.StatisticsOptions
+	)
+
+	BeforeEach(func() {
+		summary = runner.Summary{
+			Commands: map[int]runner.Command{
+				1: {Exec: "command 1", RunCount: 10},
+				2: {Exec: "command 2"},
+			},
+		}
+
+		for i := 10; i >= 1; i-- {
+			summary.EachRun = append(summary.EachRun, runner.RunStats{
+				Command:  1,
+				Duration: time.Duration(i) * time.Millisecond,
+			})
+		}
+
+		options = runner.StatisticsOptions{
+			Method:     "bootstrap",
+			Confidence: 0.95,
+			Resamples:  1000,
+			Seed:       1,
+		}
+	})
+
+	It("summarizes the durations of each command", func() {
+		Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+
+		statistics := summary.Commands[1].Statistics
+		Expect(statistics).NotTo(BeNil())
+		Expect(statistics.Mean).To(Equal(5500 * time.Microsecond))
+		Expect(statistics.Median).To(Equal(5500 * time.Microsecond))
+		Expect(statistics.P90).To(BeNumerically("~", 9100*time.Microsecond, time.Nanosecond))
+		Expect(statistics.P95).To(BeNumerically("~", 9550*time.Microsecond, time.Nanosecond))
+		Expect(statistics.P99).To(BeNumerically("~", 9910*time.Microsecond, time.Nanosecond))
+		Expect(statistics.Min).To(Equal(1 * time.Millisecond))
+		Expect(statistics.Max).To(Equal(10 * time.Millisecond))
+		Expect(statistics.StdDev).To(BeNumerically("~", 3027650*time.Nanosecond, time.Microsecond))
+	})
+
+	It("leaves the commands that didn't run without statistics", func() {
+		Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+		Expect(summary.Commands[2].Statistics).To(BeNil())
+	})
+
+	Context("using bootstrap", func() {
+		It("calculates confidence intervals around the mean and the median", func() {
+			Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+
+			statistics := summary.Commands[1].Statistics
+			Expect(statistics.MeanCI.Confidence).To(Equal(0.95))
+			Expect(statistics.MeanCI.Lower).To(BeNumerically("<", statistics.Mean))
+			Expect(statistics.MeanCI.Upper).To(BeNumerically(">", statistics.Mean))
+			Expect(statistics.MeanCI.Lower).To(BeNumerically("~", 3800*time.Microsecond, 500*time.Microsecond))
+			Expect(statistics.MeanCI.Upper).To(BeNumerically("~", 7200*time.Microsecond, 500*time.Microsecond))
+
+			Expect(statistics.MedianCI.Confidence).To(Equal(0.95))
+			Expect(statistics.MedianCI.Lower).To(BeNumerically("<", statistics.Median))
+			Expect(statistics.MedianCI.Upper).To(BeNumerically(">", statistics.Median))
+		})
+
+		It("is reproducible with the same seed", func() {
+			other := runner.Summary{Commands: map[int]runner.Command{1: {}, 2: {}}, EachRun: summary.EachRun}
+
+			Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+			Expect(runner.CalculateStatistics(&other, options)).To(Succeed())
+			Expect(other.Commands[1].Statistics).To(Equal(summary.Commands[1].Statistics))
+		})
+
+		It("requires at least one resample", func() {
+			options.Resamples = 0
+			Expect(runner.CalculateStatistics(&summary, options)).To(MatchError("resamples must be at least 1"))
+		})
+	})
+
+	Context("using analytic intervals", func() {
+		BeforeEach(func() {
+			options.Method = "analytic"
+		})
+
+		It("calculates the t interval of the mean", func() {
+			Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+
+			statistics := summary.Commands[1].Statistics
+			Expect(statistics.MeanCI.Lower).To(BeNumerically("~", 3334*time.Microsecond, 20*time.Microsecond))
+			Expect(statistics.MeanCI.Upper).To(BeNumerically("~", 7666*time.Microsecond, 20*time.Microsecond))
+		})
+
+		It("calculates the order statistics interval of the median", func() {
+			Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+
+			statistics := summary.Commands[1].Statistics
+			Expect(statistics.MedianCI.Lower).To(Equal(2 * time.Millisecond))
+			Expect(statistics.MedianCI.Upper).To(Equal(9 * time.Millisecond))
+		})
+	})
+
+	Context("when the options are invalid", func() {
+		It("validates the method", func() {
+			options.Method = "magic"
+			Expect(runner.CalculateStatistics(&summary, options)).To(MatchError("invalid method: use `bootstrap` or `analytic`"))
+		})
+
+		It("validates the confidence", func() {
+			options.Confidence = 0
+			Expect(runner.CalculateStatistics(&summary, options)).To(MatchError("confidence must be between 0 and 1"))
+		})
+	})
+
+	Context("outliers", func() {
+		BeforeEach(func() {
+			summary.EachRun = append(summary.EachRun, runner.RunStats{
+				Command:  1,
+				Duration: 100 * time.Millisecond,
+			})
+		})
+
+		Context("when no method is set", func() {
+			It("doesn't flag any outliers", func() {
+				Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+
+				Expect(summary.Commands[1].Statistics.Outliers).To(BeZero())
+				for _, run := range summary.EachRun {
+					Expect(run.Outlier).To(BeFalse())
+				}
+			})
+		})
+
+		for _, method := range []string{"iqr", "mad"} {
+			method := method
+
+			Context("using "+method, func() {
+				BeforeEach(func() {
+					options.Outliers = method
+				})
+
+				It("flags the outliers", func() {
+					Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+
+					Expect(summary.Commands[1].Statistics.Outliers).To(Equal(1))
+					Expect(summary.EachRun[10].Outlier).To(BeTrue())
+					for _, run := range summary.EachRun[:10] {
+						Expect(run.Outlier).To(BeFalse())
+					}
+				})
+
+				It("keeps the outliers in the statistics", func() {
+					Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+
+					statistics := summary.Commands[1].Statistics
+					Expect(statistics.Max).To(Equal(100 * time.Millisecond))
+					Expect(statistics.OutliersExcluded).To(BeFalse())
+				})
+
+				It("warns about the outliers", func() {
+					Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+					Expect(summary.Warnings).To(ContainElement("command 1 (command 1): 1 outlier(s) detected"))
+				})
+
+				Context("and they are excluded", func() {
+					BeforeEach(func() {
+						options.ExcludeOutliers = true
+					})
+
+					It("leaves them out of the statistics", func() {
+						Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+
+						statistics := summary.Commands[1].Statistics
+						Expect(statistics.Outliers).To(Equal(1))
+						Expect(statistics.OutliersExcluded).To(BeTrue())
+						Expect(statistics.Max).To(Equal(10 * time.Millisecond))
+						Expect(statistics.Mean).To(Equal(5500 * time.Microsecond))
+					})
+				})
+			})
+		}
+
+		It("validates the method", func() {
+			options.Outliers = "zscore"
+			Expect(runner.CalculateStatistics(&summary, options)).To(MatchError("invalid outliers method: use `iqr`, `mad` or `none`"))
+		})
+	})
+
+	Context("when an overhead is given", func() {
+		BeforeEach(func() {
+			options.Overhead = 2 * time.Millisecond
+			options.CVThreshold = 0.6
+		})
+
+		It("subtracts it from the statistics, never going below zero", func() {
+			Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+
+			statistics := summary.Commands[1].Statistics
+			Expect(statistics.Mean).To(Equal(3500 * time.Microsecond))
+			Expect(statistics.Median).To(Equal(3500 * time.Microsecond))
+			Expect(statistics.Min).To(BeZero())
+			Expect(statistics.Max).To(Equal(8 * time.Millisecond))
+			Expect(statistics.MeanCI.Lower).To(BeNumerically("<=", statistics.Mean))
+			Expect(statistics.MeanCI.Upper).To(BeNumerically(">=", statistics.Mean))
+			Expect(statistics.StdDev).To(BeNumerically("~", 3027650*time.Nanosecond, time.Microsecond))
+			Expect(statistics.OverheadSubtracted).To(Equal(2 * time.Millisecond))
+			Expect(summary.EachRun[0].Duration).To(Equal(10 * time.Millisecond))
+		})
+
+		It("gives the warnings of the statistics without the overhead", func() {
+			Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+
+			Expect(summary.Commands[1].Statistics.CV).To(BeNumerically("~", 0.87, 0.01))
+			Expect(summary.Warnings).To(ContainElement("command 1 (command 1): high coefficient of variation (0.87), results may be unreliable"))
+		})
+	})
+
+	Context("noise", func() {
+		It("reports the coefficient of variation", func() {
+			Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+			Expect(summary.Commands[1].Statistics.CV).To(BeNumerically("~", 0.55, 0.01))
+		})
+
+		It("warns when the coefficient of variation is above the threshold", func() {
+			options.CVThreshold = 0.5
+			Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+			Expect(summary.Warnings).To(ContainElement("command 1 (command 1): high coefficient of variation (0.55), results may be unreliable"))
+		})
+
+		It("doesn't warn when the coefficient of variation is below the threshold", func() {
+			options.CVThreshold = 0.6
+			Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+			Expect(summary.Warnings).NotTo(ContainElement(ContainSubstring("coefficient of variation")))
+		})
+
+		Context("when the durations are bimodal", func() {
+			BeforeEach(func() {
+				summary.EachRun = nil
+				for i := 0; i < 20; i++ {
+					summary.EachRun = append(summary.EachRun, runner.RunStats{
+						Command:  1,
+						Duration: time.Duration(1+(i%2)*20)*time.Millisecond + time.Duration(i)*time.Microsecond,
+					})
+				}
+			})
+
+			It("warns about it", func() {
+				Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+
+				Expect(summary.Commands[1].Statistics.Bimodality).To(BeNumerically(">", 5.0/9.0))
+				Expect(summary.Warnings).To(ContainElement(ContainSubstring("command 1 (command 1): durations look bimodal")))
+			})
+		})
+
+		Context("when the durations are normal-ish", func() {
+			BeforeEach(func() {
+				summary.EachRun = nil
+				for _, d := range []int{10, 11, 9, 10, 12, 8, 10, 11, 9, 10, 10, 13, 7, 10} {
+					summary.EachRun = append(summary.EachRun, runner.RunStats{
+						Command:  1,
+						Duration: time.Duration(d) * time.Millisecond,
+					})
+				}
+			})
+
+			It("doesn't warn", func() {
+				Expect(runner.CalculateStatistics(&summary, options)).To(Succeed())
+				Expect(summary.Warnings).To(BeEmpty())
+			})
+		})
+	})
+})
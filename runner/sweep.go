@@ -0,0 +1,254 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// A level is considered to still be scaling while adding workers gives back at
+// least this fraction of the throughput a linear scaling would.
+const kneeEfficiency = 0.5
+
+// Defines the result of running the same workload at one concurrency level
+// - Concurrency is the number of workers used
+// - Throughput is the number of finished runs per second
+// - Mean, P50, P95 and P99 are the latency statistics of all the runs
+// - Summary is the full summary of the execution
+type SweepLevel struct {
+	Concurrency int           `json:"concurrency"`
+	Throughput  float64       `json:"throughput"`
+	Mean        time.Duration `json:"mean"`
+	P50         time.Duration `json:"p50"`
+	P95         time.Duration `json:"p95"`
+	P99         time.Duration `json:"p99"`
+	Summary     Summary       `json:"summary"`
+}
+
+// Coefficients of a scalability model, where the relative capacity at
+// concurrency N is C(N) = N / (1 + Sigma*(N-1) + Kappa*N*(N-1)).
+// - Sigma is the contention (serialization) coefficient
+// - Kappa is the coherency (crosstalk) coefficient. Always 0 for Amdahl's law
+type ScalabilityFit struct {
+	Sigma float64 `json:"sigma"`
+	Kappa float64 `json:"kappa"`
+}
+
+// Defines the result of a Sweep
+// - Levels contains the results of each concurrency level, in ascending order
+// - Amdahl contains the Amdahl's law fit of the throughput
+// - USL contains the Universal Scalability Law fit of the throughput
+// - PeakConcurrency is the concurrency with maximum throughput predicted by the USL fit (0 if unbounded)
+// - Knee is the highest measured concurrency level before throughput stopped scaling
+type SweepReport struct {
+	Levels          []SweepLevel   `json:"levels"`
+	Amdahl          ScalabilityFit `json:"amdahl"`
+	USL             ScalabilityFit `json:"usl"`
+	PeakConcurrency float64        `json:"peak_concurrency"`
+	Knee            int            `json:"knee"`
+}
+
+// Parses a list of concurrency levels, e.g. "1,2,4,8" or "1-4,8,16".
+// Ranges are inclusive. The result is sorted and has no duplicates.
+func ParseLevels(levels string) ([]int, error) {
+	seen := map[int]bool{}
+	result := []int{}
+
+	add := func(level int) error {
+		if level < 1 {
+			return fmt.Errorf("invalid concurrency level %d: must be at least 1", level)
+		}
+		if !seen[level] {
+			seen[level] = true
+			result = append(result, level)
+		}
+		return nil
+	}
+
+	for _, def := range strings.Split(levels, ",") {
+		def = strings.TrimSpace(def)
+		bounds := strings.SplitN(def, "-", 2)
+
+		from, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid concurrency level `%s`", def)
+		}
+
+		to := from
+		if len(bounds) == 2 {
+			to, err = strconv.Atoi(bounds[1])
+			if err != nil || to < from {
+				return nil, fmt.Errorf("invalid concurrency range `%s`", def)
+			}
+		}
+
+		for level := from; level <= to; level++ {
+			if err := add(level); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.Ints(result)
+	return result, nil
+}
+
+// Sweep runs the same workload with the given runner once for each concurrency level,
+// and reports how throughput and latency change with concurrency.
+// If `cancel` is closed the sweep stops after the current level, and only the levels
+// that were run are reported.
+func Sweep(runner Runner, levels []int, cancel chan bool, commands ...string) (SweepReport, error) {
+	if len(levels) == 0 {
+		return SweepReport{}, errors.New("no concurrency levels given")
+	}
+
+	report := SweepReport{}
+
+	for _, concurrency := range levels {
+		select {
+		case <-cancel:
+			return report.fit(), nil
+		default:
+		}
+
+		summary, err := runner.Run(concurrency, cancel, commands...)
+		if err != nil {
+			return SweepReport{}, err
+		}
+
+		report.Levels = append(report.Levels, newSweepLevel(concurrency, summary))
+	}
+
+	return report.fit(), nil
+}
+
+func newSweepLevel(concurrency int, summary Summary) SweepLevel {
+	durations := sortedDurations(summary.EachRun)
+
+	level := SweepLevel{
+		Concurrency: concurrency,
+		Mean:        mean(durations),
+		P50:         percentile(durations, 50),
+		P95:         percentile(durations, 95),
+		P99:         percentile(durations, 99),
+		Summary:     summary,
+	}
+
+	if summary.Duration > 0 {
+		level.Throughput = float64(len(summary.EachRun)) / summary.Duration.Seconds()
+	}
+
+	return level
+}
+
+// fit calculates the scalability models and the knee of the measured levels.
+// The throughput of a single worker is taken from the lowest level, assuming
+// linear scaling up to it.
+func (r SweepReport) fit() SweepReport {
+	if len(r.Levels) == 0 {
+		return r
+	}
+
+	r.Knee = r.Levels[len(r.Levels)-1].Concurrency
+	for i := 1; i < len(r.Levels); i++ {
+		prev, cur := r.Levels[i-1], r.Levels[i]
+		linearGain := prev.Throughput / float64(prev.Concurrency) * float64(cur.Concurrency-prev.Concurrency)
+		if linearGain <= 0 || (cur.Throughput-prev.Throughput)/linearGain < kneeEfficiency {
+			r.Knee = prev.Concurrency
+			break
+		}
+	}
+
+	base := r.Levels[0]
+	if base.Throughput <= 0 {
+		return r
+	}
+	single := base.Throughput / float64(base.Concurrency)
+
+	// Both models are linear on x = N-1 once transformed to N/C(N) - 1:
+	// N/C(N) - 1 = Sigma*(N-1) + Kappa*N*(N-1)
+	var sxx, sxy, sxz, szz, szy float64
+	for _, level := range r.Levels {
+		if level.Concurrency == 1 || level.Throughput <= 0 {
+			continue
+		}
+
+		n := float64(level.Concurrency)
+		capacity := level.Throughput / single
+		x := n - 1
+		z := n * (n - 1)
+		y := n/capacity - 1
+
+		sxx += x * x
+		sxy += x * y
+		sxz += x * z
+		szz += z * z
+		szy += z * y
+	}
+
+	if sxx == 0 {
+		return r
+	}
+
+	r.Amdahl.Sigma = math.Max(0, sxy/sxx)
+
+	det := sxx*szz - sxz*sxz
+	if det == 0 {
+		r.USL.Sigma = r.Amdahl.Sigma
+		return r
+	}
+
+	sigma := (sxy*szz - szy*sxz) / det
+	kappa := (szy*sxx - sxy*sxz) / det
+	if kappa <= 0 {
+		r.USL.Sigma = r.Amdahl.Sigma
+		return r
+	}
+	if sigma < 0 {
+		sigma = 0
+		kappa = szy / szz
+	}
+
+	r.USL = ScalabilityFit{Sigma: sigma, Kappa: kappa}
+	if sigma < 1 {
+		r.PeakConcurrency = math.Sqrt((1 - sigma) / kappa)
+	}
+	return r
+}
+
+// WriteTable writes the throughput and latency of each level as a table,
+// followed by the scalability fits.
+func (r SweepReport) WriteTable(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(table, "CONCURRENCY\tTHROUGHPUT (/s)\tMEAN\tP50\tP95\tP99\tERRORS")
+	for _, level := range r.Levels {
+		fmt.Fprintf(table, "%d\t%.2f\t%s\t%s\t%s\t%s\t%d\n",
+			level.Concurrency,
+			level.Throughput,
+			level.Mean,
+			level.P50,
+			level.P95,
+			level.P99,
+			level.Summary.ErrorCounter,
+		)
+	}
+
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\namdahl: sigma=%.4f\n", r.Amdahl.Sigma)
+	fmt.Fprintf(w, "usl: sigma=%.4f kappa=%.6f", r.USL.Sigma, r.USL.Kappa)
+	if r.PeakConcurrency > 0 {
+		fmt.Fprintf(w, " peak=%.1f", r.PeakConcurrency)
+	}
+	_, err := fmt.Fprintf(w, "\nknee: %d\n", r.Knee)
+	return err
+}
@@ -0,0 +1,148 @@
+package runner_test
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/commandrunner/fake_command_runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("Sweep", func() {
+	var (
+		commands    []string
+		cancelChan  chan bool
+		cmdRunner   *fake_command_runner.FakeCommandRunner
+		countRunner *runner.CountRunner
+
+		commandFunc func(cmd *exec.Cmd) error
+	)
+
+	BeforeEach(func() {
+		cancelChan = make(chan bool)
+		cmdRunner = fake_command_runner.New()
+		commands = []string{"hello world"}
+		commandFunc = func(_ *exec.Cmd) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		}
+	})
+
+	JustBeforeEach(func() {
+		countRunner = runner.NewCountRunnerWithCmdRunner(cmdRunner, 8)
+
+		cmdRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: strings.Split(commands[0], " ")[0],
+			Args: strings.Split(commands[0], " ")[1:],
+		}, func(cmd *exec.Cmd) error {
+			return commandFunc(cmd)
+		})
+	})
+
+	Describe("ParseLevels", func() {
+		It("parses lists and ranges", func() {
+			levels, err := runner.ParseLevels("8,1-3, 16,2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(levels).To(Equal([]int{1, 2, 3, 8, 16}))
+		})
+
+		It("fails on invalid levels", func() {
+			_, err := runner.ParseLevels("1,a")
+			Expect(err).To(MatchError("invalid concurrency level `a`"))
+		})
+
+		It("fails on invalid ranges", func() {
+			_, err := runner.ParseLevels("4-2")
+			Expect(err).To(MatchError("invalid concurrency range `4-2`"))
+		})
+
+		It("fails on levels lower than 1", func() {
+			_, err := runner.ParseLevels("0,1")
+			Expect(err).To(MatchError("invalid concurrency level 0: must be at least 1"))
+		})
+	})
+
+	It("runs the workload for each level", func() {
+		report, err := runner.Sweep(countRunner, []int{1, 2, 4}, cancelChan, commands...)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(report.Levels).To(HaveLen(3))
+		for i, concurrency := range []int{1, 2, 4} {
+			level := report.Levels[i]
+			Expect(level.Concurrency).To(Equal(concurrency))
+			Expect(level.Summary.EachRun).To(HaveLen(8))
+			Expect(level.P50).To(BeNumerically("~", 20*time.Millisecond, 10*time.Millisecond))
+		}
+	})
+
+	Context("when the workload scales linearly", func() {
+		It("reports the throughput growing with concurrency", func() {
+			report, err := runner.Sweep(countRunner, []int{1, 2, 4}, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Levels[1].Throughput).To(BeNumerically("~", 2*report.Levels[0].Throughput, 0.5*report.Levels[0].Throughput))
+			Expect(report.Levels[2].Throughput).To(BeNumerically("~", 4*report.Levels[0].Throughput, report.Levels[0].Throughput))
+			Expect(report.Amdahl.Sigma).To(BeNumerically("<", 0.2))
+			Expect(report.Knee).To(Equal(4))
+		})
+	})
+
+	Context("when the workload is serialized", func() {
+		BeforeEach(func() {
+			lock := &sync.Mutex{}
+			commandFunc = func(_ *exec.Cmd) error {
+				lock.Lock()
+				defer lock.Unlock()
+				time.Sleep(20 * time.Millisecond)
+				return nil
+			}
+		})
+
+		It("reports the contention and the knee", func() {
+			report, err := runner.Sweep(countRunner, []int{1, 2, 4}, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Amdahl.Sigma).To(BeNumerically(">", 0.8))
+			Expect(report.Knee).To(Equal(1))
+		})
+	})
+
+	Context("when cancelled", func() {
+		It("stops sweeping", func() {
+			close(cancelChan)
+			report, err := runner.Sweep(countRunner, []int{1, 2, 4}, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Levels).To(BeEmpty())
+		})
+	})
+
+	Context("when there are no levels", func() {
+		It("returns an error", func() {
+			_, err := runner.Sweep(countRunner, []int{}, cancelChan, commands...)
+			Expect(err).To(MatchError("no concurrency levels given"))
+		})
+	})
+
+	Describe("WriteTable", func() {
+		It("writes a row for each level and the fits", func() {
+			report, err := runner.Sweep(countRunner, []int{1, 2}, cancelChan, commands...)
+			Expect(err).NotTo(HaveOccurred())
+
+			buffer := bytes.NewBuffer(nil)
+			Expect(report.WriteTable(buffer)).To(Succeed())
+
+			lines := strings.Split(buffer.String(), "\n")
+			Expect(lines[0]).To(MatchRegexp(`^CONCURRENCY\s+THROUGHPUT \(/s\)\s+MEAN\s+P50\s+P95\s+P99\s+ERRORS$`))
+			Expect(lines[1]).To(MatchRegexp(`^1\s+\d+\.\d\d\s+`))
+			Expect(lines[2]).To(MatchRegexp(`^2\s+\d+\.\d\d\s+`))
+			Expect(buffer.String()).To(ContainSubstring("amdahl: sigma="))
+			Expect(buffer.String()).To(ContainSubstring("knee: "))
+		})
+	})
+})
@@ -0,0 +1,229 @@
+package runner
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Size of the sectors /proc/diskstats counts in, regardless of the device.
+const sectorSize = 512
+
+// Defines the usage of the host since the previous sample
+// - Time is when the sample was taken
+// - CPU is the percentage (0-100) of time the CPUs were busy
+// - IOWait is the percentage (0-100) of time the CPUs were idle waiting for IO
+// - MemoryUsed is the memory in use (total minus available) in bytes
+// - Load1 is the 1 minute load average
+// - DiskReadRate and DiskWriteRate are the bytes per second read from and written to the disks
+type SystemSample struct {
+	Time          time.Time `json:"time"`
+	CPU           float64   `json:"cpu"`
+	IOWait        float64   `json:"iowait"`
+	MemoryUsed    uint64    `json:"memory_used"`
+	Load1         float64   `json:"load1"`
+	DiskReadRate  float64   `json:"disk_read_rate"`
+	DiskWriteRate float64   `json:"disk_write_rate"`
+}
+
+// SystemSampler samples the usage of the host from /proc at a fixed interval,
+// to tell whether the host was saturated during a benchmark.
+// Outside of Linux no samples are taken.
+type SystemSampler struct {
+	interval time.Duration
+	samples  []SystemSample
+	previous procCounters
+
+	stop chan bool
+	done chan bool
+}
+
+// procCounters are the cumulative counters the samples are the difference of.
+type procCounters struct {
+	time           time.Time
+	cpuTotal       uint64
+	cpuIdle        uint64
+	cpuIOWait      uint64
+	sectorsRead    uint64
+	sectorsWritten uint64
+}
+
+// Creates a new instance of the SystemSampler, taking a sample every interval.
+func NewSystemSampler(interval time.Duration) (*SystemSampler, error) {
+	if interval <= 0 {
+		return nil, errors.New("sampling interval must be positive")
+	}
+
+	return &SystemSampler{
+		interval: interval,
+		stop:     make(chan bool),
+		done:     make(chan bool),
+	}, nil
+}
+
+// Start starts sampling in the background, until Stop is called.
+func (s *SystemSampler) Start() {
+	s.previous, _ = readProcCounters()
+	go s.sample()
+}
+
+// Stop takes a last sample, and returns all the samples taken.
+func (s *SystemSampler) Stop() []SystemSample {
+	close(s.stop)
+	<-s.done
+
+	s.takeSample()
+	return s.samples
+}
+
+func (s *SystemSampler) sample() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.takeSample()
+		}
+	}
+}
+
+func (s *SystemSampler) takeSample() {
+	current, err := readProcCounters()
+	if err != nil {
+		return
+	}
+
+	previous := s.previous
+	if previous.time.IsZero() {
+		s.previous = current
+		return
+	}
+
+	// The CPU times are counted in clock ticks, so with short intervals they
+	// might not have changed yet. The next sample covers both intervals then.
+	cpuTotal := float64(current.cpuTotal - previous.cpuTotal)
+	seconds := current.time.Sub(previous.time).Seconds()
+	if cpuTotal <= 0 || seconds <= 0 {
+		return
+	}
+	s.previous = current
+
+	sample := SystemSample{
+		Time:          current.time,
+		CPU:           100 - float64(current.cpuIdle+current.cpuIOWait-previous.cpuIdle-previous.cpuIOWait)/cpuTotal*100,
+		IOWait:        float64(current.cpuIOWait-previous.cpuIOWait) / cpuTotal * 100,
+		MemoryUsed:    readMemoryUsed(),
+		DiskReadRate:  float64((current.sectorsRead-previous.sectorsRead)*sectorSize) / seconds,
+		DiskWriteRate: float64((current.sectorsWritten-previous.sectorsWritten)*sectorSize) / seconds,
+	}
+
+	if load := LoadAverage(); load != nil {
+		sample.Load1 = load[0]
+	}
+
+	s.samples = append(s.samples, sample)
+}
+
+// readProcCounters reads the CPU times of /proc/stat and the sectors of the
+// physical disks of /proc/diskstats.
+func readProcCounters() (procCounters, error) {
+	counters := procCounters{time: time.Now()}
+
+	// cpu user nice system idle iowait irq softirq steal guest guest_nice
+	fields := strings.Fields(readProcLine("/proc/stat", "cpu "))
+	if len(fields) < 9 {
+		return procCounters{}, errors.New("can't read /proc/stat")
+	}
+
+	// guest and guest_nice are already accounted for in user and nice.
+	for i, field := range fields[1:9] {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return procCounters{}, err
+		}
+
+		counters.cpuTotal += value
+		switch i {
+		case 3:
+			counters.cpuIdle = value
+		case 4:
+			counters.cpuIOWait = value
+		}
+	}
+
+	counters.sectorsRead, counters.sectorsWritten = readDiskSectors()
+	return counters, nil
+}
+
+// readDiskSectors sums the sectors read and written of the physical disks,
+// leaving out partitions and virtual devices (loop, ram, device mapper) so
+// nothing is counted twice.
+func readDiskSectors() (uint64, uint64) {
+	contents, err := ioutil.ReadFile("/proc/diskstats")
+	if err != nil {
+		return 0, 0
+	}
+
+	var read, written uint64
+	for _, line := range strings.Split(string(contents), "\n") {
+		// major minor name reads merged sectors_read ms writes merged sectors_written ...
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			continue
+		}
+
+		if _, err := os.Stat("/sys/block/" + fields[2] + "/device"); err != nil {
+			continue
+		}
+
+		sectors, _ := strconv.ParseUint(fields[5], 10, 64)
+		read += sectors
+		sectors, _ = strconv.ParseUint(fields[9], 10, 64)
+		written += sectors
+	}
+
+	return read, written
+}
+
+// readMemoryUsed returns MemTotal minus MemAvailable of /proc/meminfo, in bytes.
+func readMemoryUsed() uint64 {
+	available := strings.Fields(readProcField("/proc/meminfo", "MemAvailable"))
+	if len(available) == 0 {
+		return 0
+	}
+
+	kilobytes, err := strconv.ParseUint(available[0], 10, 64)
+	total := readMemoryTotal()
+	if err != nil || kilobytes*1024 > total {
+		return 0
+	}
+
+	return total - kilobytes*1024
+}
+
+// readProcLine returns the first line of the file starting with the prefix.
+func readProcLine(path, prefix string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), prefix) {
+			return scanner.Text()
+		}
+	}
+
+	return ""
+}
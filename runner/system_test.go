@@ -0,0 +1,48 @@
+package runner_test
+
+import (
+	"runtime"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("SystemSampler", func() {
+	It("samples the usage of the host until stopped", func() {
+		if runtime.GOOS != "linux" {
+			Skip("the usage is only sampled on Linux")
+		}
+
+		sampler, err := runner.NewSystemSampler(50 * time.Millisecond)
+		Expect(err).NotTo(HaveOccurred())
+
+		sampler.Start()
+		time.Sleep(300 * time.Millisecond)
+		samples := sampler.Stop()
+
+		Expect(samples).NotTo(BeEmpty())
+		for i, sample := range samples {
+			Expect(sample.CPU).To(BeNumerically(">=", 0))
+			Expect(sample.CPU).To(BeNumerically("<=", 100))
+			Expect(sample.IOWait).To(BeNumerically(">=", 0))
+			Expect(sample.IOWait).To(BeNumerically("<=", 100))
+			Expect(sample.MemoryUsed).To(BeNumerically(">", 0))
+			Expect(sample.Load1).To(BeNumerically(">=", 0))
+			Expect(sample.DiskReadRate).To(BeNumerically(">=", 0))
+			Expect(sample.DiskWriteRate).To(BeNumerically(">=", 0))
+
+			if i > 0 {
+				Expect(sample.Time).To(BeTemporally(">", samples[i-1].Time))
+			}
+		}
+	})
+
+	Context("when the interval isn't positive", func() {
+		It("returns an error", func() {
+			_, err := runner.NewSystemSampler(0)
+			Expect(err).To(MatchError("sampling interval must be positive"))
+		})
+	})
+})
@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"errors"
+	"time"
+)
+
+// Defines the runs finished within a bucket of time
+// - Completed is the number of runs finished
+// - Errors is the number of those runs that failed
+// - P50, P90 and P99 are the latency percentiles of those runs
+type BucketStats struct {
+	Completed int           `json:"completed"`
+	Errors    int           `json:"errors"`
+	P50       time.Duration `json:"p50"`
+	P90       time.Duration `json:"p90"`
+	P99       time.Duration `json:"p99"`
+}
+
+// Bucket is a period of the benchmark
+// - Start is the offset of the bucket from the Summary.StartTime
+// - Commands breaks down the bucket stats by command index (from Summary.Commands)
+type Bucket struct {
+	Start time.Duration `json:"start"`
+	BucketStats
+	Commands map[int]BucketStats `json:"commands,omitempty"`
+}
+
+// BuildTimeSeries sets the summary's TimeSeries, bucketing the runs in EachRun by
+// the time they finished, in buckets of the given width.
+// Every bucket from the start until the end of the benchmark is present, even if
+// no run finished within it.
+func BuildTimeSeries(summary *Summary, width time.Duration) error {
+	if width <= 0 {
+		return errors.New("bucket width must be positive")
+	}
+
+	count := int(summary.Duration/width) + 1
+	runs := make([][]RunStats, count)
+
+	for _, run := range summary.EachRun {
+		finished := run.StartTime.Add(run.Duration).Sub(summary.StartTime)
+		idx := int(finished / width)
+		if idx < 0 {
+			idx = 0
+		}
+		if idx >= count {
+			idx = count - 1
+		}
+		runs[idx] = append(runs[idx], run)
+	}
+
+	// The last bucket is only kept if something finished within it.
+	if summary.Duration%width == 0 && len(runs[count-1]) == 0 && count > 1 {
+		runs = runs[:count-1]
+	}
+
+	summary.TimeSeries = make([]Bucket, len(runs))
+	for i, bucketRuns := range runs {
+		perCommand := map[int][]RunStats{}
+		for _, run := range bucketRuns {
+			perCommand[run.Command] = append(perCommand[run.Command], run)
+		}
+
+		bucket := Bucket{
+			Start:       time.Duration(i) * width,
+			BucketStats: newBucketStats(bucketRuns),
+			Commands:    map[int]BucketStats{},
+		}
+		for cmd, cmdRuns := range perCommand {
+			bucket.Commands[cmd] = newBucketStats(cmdRuns)
+		}
+
+		summary.TimeSeries[i] = bucket
+	}
+
+	return nil
+}
+
+func newBucketStats(runs []RunStats) BucketStats {
+	durations := sortedDurations(runs)
+
+	stats := BucketStats{
+		Completed: len(runs),
+		P50:       percentile(durations, 50),
+		P90:       percentile(durations, 90),
+		P99:       percentile(durations, 99),
+	}
+
+	for _, run := range runs {
+		if run.Failed {
+			stats.Errors++
+		}
+	}
+
+	return stats
+}
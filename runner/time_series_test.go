@@ -0,0 +1,102 @@
+package runner_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("BuildTimeSeries", func() {
+	var (
+		summary runner.Summary
+		start   time.Time
+	)
+
+	run := func(command int, startOffset, duration time.Duration, failed bool) runner.RunStats {
+		return runner.RunStats{
+			Command:   command,
+			StartTime: start.Add(startOffset),
+			Duration:  duration,
+			Failed:    failed,
+		}
+	}
+
+	BeforeEach(func() {
+		start = time.Now()
+		summary = runner.Summary{
+			StartTime: start,
+			Duration:  3500 * time.Millisecond,
+			EachRun: []runner.RunStats{
+				run(1, 0, 100*time.Millisecond, false),
+				run(2, 0, 900*time.Millisecond, true),
+				run(1, 100*time.Millisecond, 200*time.Millisecond, false),
+				run(1, 900*time.Millisecond, 200*time.Millisecond, false),
+				run(2, 3*time.Second, 300*time.Millisecond, false),
+			},
+		}
+	})
+
+	It("buckets the runs by the time they finished", func() {
+		Expect(runner.BuildTimeSeries(&summary, time.Second)).To(Succeed())
+
+		Expect(summary.TimeSeries).To(HaveLen(4))
+		Expect(summary.TimeSeries[0].Start).To(Equal(time.Duration(0)))
+		Expect(summary.TimeSeries[0].Completed).To(Equal(3))
+		Expect(summary.TimeSeries[0].Errors).To(Equal(1))
+
+		Expect(summary.TimeSeries[1].Start).To(Equal(time.Second))
+		Expect(summary.TimeSeries[1].Completed).To(Equal(1))
+		Expect(summary.TimeSeries[1].Errors).To(BeZero())
+	})
+
+	It("includes the buckets where nothing finished", func() {
+		Expect(runner.BuildTimeSeries(&summary, time.Second)).To(Succeed())
+
+		Expect(summary.TimeSeries[2].Start).To(Equal(2 * time.Second))
+		Expect(summary.TimeSeries[2].Completed).To(BeZero())
+		Expect(summary.TimeSeries[2].P50).To(BeZero())
+		Expect(summary.TimeSeries[3].Completed).To(Equal(1))
+	})
+
+	It("calculates the latency percentiles of each bucket", func() {
+		Expect(runner.BuildTimeSeries(&summary, time.Second)).To(Succeed())
+
+		Expect(summary.TimeSeries[0].P50).To(Equal(200 * time.Millisecond))
+		Expect(summary.TimeSeries[0].P90).To(Equal(760 * time.Millisecond))
+		Expect(summary.TimeSeries[0].P99).To(Equal(886 * time.Millisecond))
+	})
+
+	It("breaks down each bucket per command", func() {
+		Expect(runner.BuildTimeSeries(&summary, time.Second)).To(Succeed())
+
+		commands := summary.TimeSeries[0].Commands
+		Expect(commands).To(HaveLen(2))
+		Expect(commands[1].Completed).To(Equal(2))
+		Expect(commands[1].Errors).To(BeZero())
+		Expect(commands[1].P50).To(Equal(150 * time.Millisecond))
+		Expect(commands[2].Completed).To(Equal(1))
+		Expect(commands[2].Errors).To(Equal(1))
+		Expect(commands[2].P50).To(Equal(900 * time.Millisecond))
+
+		Expect(summary.TimeSeries[1].Commands).To(HaveLen(1))
+		Expect(summary.TimeSeries[2].Commands).To(BeEmpty())
+	})
+
+	It("uses the given width", func() {
+		Expect(runner.BuildTimeSeries(&summary, 500*time.Millisecond)).To(Succeed())
+
+		Expect(summary.TimeSeries).To(HaveLen(7))
+		Expect(summary.TimeSeries[0].Completed).To(Equal(2))
+		Expect(summary.TimeSeries[1].Completed).To(Equal(1))
+		Expect(summary.TimeSeries[2].Completed).To(Equal(1))
+		Expect(summary.TimeSeries[6].Completed).To(Equal(1))
+	})
+
+	Context("when the width is not positive", func() {
+		It("returns an error", func() {
+			Expect(runner.BuildTimeSeries(&summary, 0)).To(MatchError("bucket width must be positive"))
+		})
+	})
+})
@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// traceEvent is an event of the Chrome Trace Event format.
+// Timestamps and durations are in microseconds.
+type traceEvent struct {
+	Name     string                 `json:"name"`
+	Category string                 `json:"cat,omitempty"`
+	Phase    string                 `json:"ph"`
+	Time     float64                `json:"ts"`
+	Duration float64                `json:"dur"`
+	Process  int                    `json:"pid"`
+	Thread   int                    `json:"tid"`
+	Args     map[string]interface{} `json:"args,omitempty"`
+}
+
+type trace struct {
+	TraceEvents     []traceEvent `json:"traceEvents"`
+	DisplayTimeUnit string       `json:"displayTimeUnit"`
+}
+
+// WriteTrace writes every run of the summary as a complete event in the Chrome
+// Trace Event format, which can be loaded in chrome://tracing or Perfetto.
+// Each worker gets its own track, and the events are named after their command.
+func (s Summary) WriteTrace(w io.Writer) error {
+	t := trace{
+		DisplayTimeUnit: "ms",
+		TraceEvents: []traceEvent{
+			{Name: "process_name", Phase: "M", Process: 1, Args: map[string]interface{}{"name": "bender"}},
+		},
+	}
+
+	workers := map[int]bool{}
+	for _, runStats := range s.EachRun {
+		workers[runStats.Worker] = true
+	}
+
+	ids := []int{}
+	for worker := range workers {
+		ids = append(ids, worker)
+	}
+	sort.Ints(ids)
+
+	for _, worker := range ids {
+		t.TraceEvents = append(t.TraceEvents, traceEvent{
+			Name:    "thread_name",
+			Phase:   "M",
+			Process: 1,
+			Thread:  worker,
+			Args:    map[string]interface{}{"name": fmt.Sprintf("worker %d", worker)},
+		})
+	}
+
+	for _, runStats := range s.EachRun {
+		t.TraceEvents = append(t.TraceEvents, traceEvent{
+			Name:     s.Commands[runStats.Command].Exec,
+			Category: "run",
+			Phase:    "X",
+			Time:     float64(runStats.StartTime.Sub(s.StartTime).Nanoseconds()) / 1000,
+			Duration: float64(runStats.Duration.Nanoseconds()) / 1000,
+			Process:  1,
+			Thread:   runStats.Worker,
+			Args: map[string]interface{}{
+				"command":   runStats.Command,
+				"iteration": runStats.Iteration,
+				"failed":    runStats.Failed,
+				"exit_code": runStats.ExitCode,
+			},
+		})
+	}
+
+	return json.NewEncoder(w).Encode(&t)
+}
@@ -0,0 +1,98 @@
+package runner_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("WriteTrace", func() {
+	type event struct {
+		Name     string                 `json:"name"`
+		Phase    string                 `json:"ph"`
+		Time     float64                `json:"ts"`
+		Duration float64                `json:"dur"`
+		Process  int                    `json:"pid"`
+		Thread   int                    `json:"tid"`
+		Args     map[string]interface{} `json:"args"`
+	}
+
+	var (
+		summary runner.Summary
+		events  []event
+		output  string
+	)
+
+	BeforeEach(func() {
+		start := time.Now()
+		summary = runner.Summary{
+			Commands: map[int]runner.Command{
+				1: {Exec: "ls"},
+				2: {Exec: "sleep 1"},
+			},
+			StartTime: start,
+			EachRun: []runner.RunStats{
+				{Command: 1, Worker: 1, Iteration: 1, StartTime: start.Add(time.Millisecond), Duration: 2500 * time.Microsecond},
+				{Command: 2, Worker: 2, Iteration: 1, StartTime: start.Add(2 * time.Millisecond), Duration: time.Second, Failed: true, ExitCode: 3},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		buffer := bytes.NewBuffer([]byte{})
+		Expect(summary.WriteTrace(buffer)).To(Succeed())
+		output = buffer.String()
+
+		var trace struct {
+			TraceEvents []event `json:"traceEvents"`
+		}
+		Expect(json.Unmarshal(buffer.Bytes(), &trace)).To(Succeed())
+		events = trace.TraceEvents
+	})
+
+	It("names a track for each worker", func() {
+		Expect(events[1].Name).To(Equal("thread_name"))
+		Expect(events[1].Phase).To(Equal("M"))
+		Expect(events[1].Thread).To(Equal(1))
+		Expect(events[1].Args["name"]).To(Equal("worker 1"))
+
+		Expect(events[2].Thread).To(Equal(2))
+		Expect(events[2].Args["name"]).To(Equal("worker 2"))
+	})
+
+	It("writes each run as a complete event in its worker track", func() {
+		Expect(events).To(HaveLen(5))
+
+		Expect(events[3].Name).To(Equal("ls"))
+		Expect(events[3].Phase).To(Equal("X"))
+		Expect(events[3].Time).To(Equal(1000.0))
+		Expect(events[3].Duration).To(Equal(2500.0))
+		Expect(events[3].Thread).To(Equal(1))
+
+		Expect(events[4].Name).To(Equal("sleep 1"))
+		Expect(events[4].Time).To(Equal(2000.0))
+		Expect(events[4].Duration).To(Equal(1000000.0))
+		Expect(events[4].Thread).To(Equal(2))
+	})
+
+	Context("when a run took no time", func() {
+		BeforeEach(func() {
+			summary.EachRun[0].Duration = 0
+		})
+
+		It("still includes its duration, as complete events require it", func() {
+			Expect(output).To(ContainSubstring(`"ph":"X","ts":1000,"dur":0,`))
+		})
+	})
+
+	It("includes the failure and exit code of each run", func() {
+		Expect(events[3].Args).To(HaveKeyWithValue("failed", false))
+		Expect(events[3].Args).To(HaveKeyWithValue("exit_code", 0.0))
+		Expect(events[4].Args).To(HaveKeyWithValue("failed", true))
+		Expect(events[4].Args).To(HaveKeyWithValue("exit_code", 3.0))
+	})
+})
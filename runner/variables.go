@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Variables are the names of the {{name}} placeholders replaced on every run
+// - iteration is the number (starting at 1) of the run within its worker
+// - worker is the index (starting at 1) of the worker running it
+// - command is the index (starting at 1) of the command
+// - uuid is a random UUID (version 4), different on every run
+// - timestamp is the time the run is prepared, in nanoseconds since the Unix epoch
+var Variables = []string{"iteration", "worker", "command", "uuid", "timestamp"}
+
+// setVariables replaces the placeholders of the variables in the arguments of
+// the command, and exposes them to it as BENDER_<NAME> environment variables.
+func setVariables(cmd *exec.Cmd, stats *RunStats) {
+	values := map[string]string{
+		"iteration": strconv.Itoa(stats.Iteration),
+		"worker":    strconv.Itoa(stats.Worker),
+		"command":   strconv.Itoa(stats.Command),
+		"uuid":      newUUID(),
+		"timestamp": strconv.FormatInt(time.Now().UnixNano(), 10),
+	}
+
+	replacePlaceholders(cmd, values)
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	for _, name := range Variables {
+		cmd.Env = append(cmd.Env, "BENDER_"+strings.ToUpper(name)+"="+values[name])
+	}
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
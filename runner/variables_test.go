@@ -0,0 +1,70 @@
+package runner_test
+
+import (
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/commandrunner/fake_command_runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("Variables", func() {
+	var (
+		cmdRunner *fake_command_runner.FakeCommandRunner
+		summary   runner.Summary
+		before    time.Time
+	)
+
+	BeforeEach(func() {
+		cmdRunner = fake_command_runner.New()
+		countRunner := runner.NewCountRunnerWithCmdRunner(cmdRunner, 3)
+
+		before = time.Now()
+		var err error
+		summary, err = countRunner.Run(1, make(chan bool), "echo {{iteration}} {{worker}} {{command}} {{uuid}} {{timestamp}} {{other}}")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("replaces the placeholders of the variables on every run", func() {
+		executed := cmdRunner.ExecutedCommands()
+		Expect(executed).To(HaveLen(3))
+
+		uuids := map[string]bool{}
+		for i, cmd := range executed {
+			Expect(cmd.Args[1:4]).To(Equal([]string{strconv.Itoa(i + 1), "1", "1"}))
+			Expect(cmd.Args[4]).To(MatchRegexp(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`))
+			uuids[cmd.Args[4]] = true
+
+			timestamp, err := strconv.ParseInt(cmd.Args[5], 10, 64)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(time.Unix(0, timestamp)).To(BeTemporally("~", before, time.Minute))
+
+			Expect(cmd.Args[6]).To(Equal("{{other}}"))
+		}
+		Expect(uuids).To(HaveLen(3))
+		Expect(summary.Commands[1].Exec).To(ContainSubstring("{{uuid}}"))
+	})
+
+	It("keeps the literal placeholders of the arguments", func() {
+		countRunner := runner.NewCountRunnerWithCmdRunner(cmdRunner, 1)
+		_, err := countRunner.Run(1, make(chan bool), "docker ps --format {{.Names}}-{{worker}}")
+		Expect(err).NotTo(HaveOccurred())
+
+		executed := cmdRunner.ExecutedCommands()
+		Expect(executed[len(executed)-1].Args).To(Equal([]string{"docker", "ps", "--format", "{{.Names}}-1"}))
+	})
+
+	It("exposes the variables as environment variables", func() {
+		for i, cmd := range cmdRunner.ExecutedCommands() {
+			Expect(cmd.Env).To(ContainElement("BENDER_ITERATION=" + strconv.Itoa(i+1)))
+			Expect(cmd.Env).To(ContainElement("BENDER_WORKER=1"))
+			Expect(cmd.Env).To(ContainElement("BENDER_COMMAND=1"))
+			Expect(cmd.Env).To(ContainElement("BENDER_UUID=" + cmd.Args[4]))
+			Expect(cmd.Env).To(ContainElement("BENDER_TIMESTAMP=" + cmd.Args[5]))
+			Expect(cmd.Env).To(ContainElement(HavePrefix("PATH=")))
+		}
+	})
+})
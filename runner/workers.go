@@ -0,0 +1,65 @@
+package runner
+
+import "time"
+
+// Defines the runs executed by one of the workers
+// - Runs is the number of runs the worker executed
+// - Errors is the number of those runs that failed
+// - Throughput is the number of runs per second, over the whole benchmark duration
+// - Mean, P50 and P99 are the latency statistics of those runs
+// - MeanScheduleDelay and MaxScheduleDelay are the statistics of the delay between dequeuing a run and starting its process
+type Worker struct {
+	Runs              int           `json:"runs"`
+	Errors            int           `json:"errors"`
+	Throughput        float64       `json:"throughput"`
+	Mean              time.Duration `json:"mean"`
+	P50               time.Duration `json:"p50"`
+	P99               time.Duration `json:"p99"`
+	MeanScheduleDelay time.Duration `json:"mean_schedule_delay"`
+	MaxScheduleDelay  time.Duration `json:"max_schedule_delay"`
+}
+
+// SummarizeWorkers sets the summary's Workers, breaking down the runs in EachRun
+// by the worker that executed them. Comparing the workers shows if the load was
+// evenly spread or if some of them were starved.
+func SummarizeWorkers(summary *Summary) {
+	runs := map[int][]RunStats{}
+	for _, runStats := range summary.EachRun {
+		runs[runStats.Worker] = append(runs[runStats.Worker], runStats)
+	}
+
+	summary.Workers = map[int]Worker{}
+	for worker, workerRuns := range runs {
+		summary.Workers[worker] = newWorker(workerRuns, summary.Duration)
+	}
+}
+
+func newWorker(runs []RunStats, duration time.Duration) Worker {
+	durations := sortedDurations(runs)
+	delays := make([]time.Duration, len(runs))
+
+	worker := Worker{
+		Runs: len(runs),
+		Mean: mean(durations),
+		P50:  percentile(durations, 50),
+		P99:  percentile(durations, 99),
+	}
+
+	for i, runStats := range runs {
+		if runStats.Failed {
+			worker.Errors++
+		}
+
+		delays[i] = runStats.ScheduleDelay
+		if runStats.ScheduleDelay > worker.MaxScheduleDelay {
+			worker.MaxScheduleDelay = runStats.ScheduleDelay
+		}
+	}
+	worker.MeanScheduleDelay = mean(delays)
+
+	if duration > 0 {
+		worker.Throughput = float64(len(runs)) / duration.Seconds()
+	}
+
+	return worker
+}
@@ -0,0 +1,69 @@
+package runner_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tscolari/bender/runner"
+)
+
+var _ = Describe("SummarizeWorkers", func() {
+	var summary runner.Summary
+
+	run := func(worker int, duration, delay time.Duration, failed bool) runner.RunStats {
+		return runner.RunStats{
+			Command:       1,
+			Worker:        worker,
+			Duration:      duration,
+			ScheduleDelay: delay,
+			Failed:        failed,
+		}
+	}
+
+	BeforeEach(func() {
+		summary = runner.Summary{
+			Duration: 2 * time.Second,
+			EachRun: []runner.RunStats{
+				run(1, 100*time.Millisecond, time.Millisecond, false),
+				run(2, 900*time.Millisecond, 5*time.Millisecond, true),
+				run(1, 300*time.Millisecond, 3*time.Millisecond, false),
+				run(1, 200*time.Millisecond, 2*time.Millisecond, true),
+			},
+		}
+	})
+
+	It("breaks down the runs per worker", func() {
+		runner.SummarizeWorkers(&summary)
+
+		Expect(summary.Workers).To(HaveLen(2))
+		Expect(summary.Workers[1].Runs).To(Equal(3))
+		Expect(summary.Workers[1].Errors).To(Equal(1))
+		Expect(summary.Workers[2].Runs).To(Equal(1))
+		Expect(summary.Workers[2].Errors).To(Equal(1))
+	})
+
+	It("calculates the throughput of each worker", func() {
+		runner.SummarizeWorkers(&summary)
+
+		Expect(summary.Workers[1].Throughput).To(Equal(1.5))
+		Expect(summary.Workers[2].Throughput).To(Equal(0.5))
+	})
+
+	It("calculates the latency of each worker", func() {
+		runner.SummarizeWorkers(&summary)
+
+		Expect(summary.Workers[1].Mean).To(Equal(200 * time.Millisecond))
+		Expect(summary.Workers[1].P50).To(Equal(200 * time.Millisecond))
+		Expect(summary.Workers[1].P99).To(Equal(298 * time.Millisecond))
+		Expect(summary.Workers[2].P50).To(Equal(900 * time.Millisecond))
+	})
+
+	It("calculates the scheduling delay of each worker", func() {
+		runner.SummarizeWorkers(&summary)
+
+		Expect(summary.Workers[1].MeanScheduleDelay).To(Equal(2 * time.Millisecond))
+		Expect(summary.Workers[1].MaxScheduleDelay).To(Equal(3 * time.Millisecond))
+		Expect(summary.Workers[2].MeanScheduleDelay).To(Equal(5 * time.Millisecond))
+	})
+})
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tscolari/bender/runner"
+	"github.com/urfave/cli"
+)
+
+func searchCommand() cli.Command {
+	return cli.Command{
+		Name:  "search",
+		Usage: "search for the highest arrival rate the commands can sustain within an SLO",
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name:  "command",
+				Usage: "command(s) to run. May be set more than once",
+			},
+			cli.IntFlag{
+				Name:  "concurrency",
+				Value: 10,
+				Usage: "maximum number of commands running at the same time",
+			},
+			cli.DurationFlag{
+				Name:  "slo-p99",
+				Usage: "maximum p99 latency allowed in a trial",
+			},
+			cli.Float64Flag{
+				Name:  "slo-errors",
+				Value: 0,
+				Usage: "maximum percentage of failed runs allowed in a trial",
+			},
+			cli.Float64Flag{
+				Name:  "min-rate",
+				Value: 1,
+				Usage: "lowest arrival rate (per second) to try",
+			},
+			cli.Float64Flag{
+				Name:  "max-rate",
+				Value: 100,
+				Usage: "highest arrival rate (per second) to try",
+			},
+			cli.DurationFlag{
+				Name:  "trial-duration",
+				Value: 10 * time.Second,
+				Usage: "duration of each trial",
+			},
+			cli.Float64Flag{
+				Name:  "tolerance",
+				Value: 1,
+				Usage: "stop when the passing and failing rates are this close (per second)",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if len(c.StringSlice("command")) == 0 {
+				return errors.New("Missing at least one `--command` argument")
+			}
+
+			slo := runner.SLO{
+				P99:          c.Duration("slo-p99"),
+				MaxErrorRate: c.Float64("slo-errors"),
+			}
+
+			options := runner.SearchOptions{
+				MinRate:   c.Float64("min-rate"),
+				MaxRate:   c.Float64("max-rate"),
+				Tolerance: c.Float64("tolerance"),
+			}
+
+			trialDuration := c.Duration("trial-duration")
+			newRunner := func(rate float64) runner.Runner {
+				return metadataRunner{Runner: runner.NewRateRunner(rate, trialDuration), version: c.App.Version}
+			}
+
+			cancelChan := make(chan bool)
+			listenForShutdown(cancelChan)
+
+			result, err := runner.Search(newRunner, slo, options, c.Int("concurrency"), cancelChan, c.StringSlice("command")...)
+			if err != nil {
+				return fmt.Errorf("Failed to run: %s", err.Error())
+			}
+
+			err = json.NewEncoder(os.Stdout).Encode(&result)
+			if err != nil {
+				return fmt.Errorf("Failed to run: %s", err.Error())
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/tscolari/bender/runner"
+	"github.com/urfave/cli"
+)
+
+func sweepCommand() cli.Command {
+	return cli.Command{
+		Name:  "sweep",
+		Usage: "run the same workload across multiple concurrency levels and report how it scales",
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "count",
+				Value: 10,
+				Usage: "how many times should the command run on each concurrency level",
+			},
+			cli.StringFlag{
+				Name:  "levels",
+				Value: "1,2,4,8",
+				Usage: "concurrency levels to run, as a list and/or ranges, e.g. 1,2,4,8 or 1-16",
+			},
+			cli.StringSliceFlag{
+				Name:  "command",
+				Usage: "command(s) to run. May be set more than once",
+			},
+			cli.StringFlag{
+				Name:  "format",
+				Value: "json",
+				Usage: "output format: json or table",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if len(c.StringSlice("command")) == 0 {
+				return errors.New("Missing at least one `--command` argument")
+			}
+
+			format := c.String("format")
+			if format != "json" && format != "table" {
+				return fmt.Errorf("invalid format `%s`: use `json` or `table`", format)
+			}
+
+			levels, err := runner.ParseLevels(c.String("levels"))
+			if err != nil {
+				return err
+			}
+
+			cancelChan := make(chan bool)
+			listenForShutdown(cancelChan)
+
+			countRunner := metadataRunner{Runner: runner.NewCountRunner(c.Int("count")), version: c.App.Version}
+			report, err := runner.Sweep(countRunner, levels, cancelChan, c.StringSlice("command")...)
+			if err != nil {
+				return fmt.Errorf("Failed to run: %s", err.Error())
+			}
+
+			if format == "table" {
+				err = report.WriteTable(os.Stdout)
+			} else {
+				err = json.NewEncoder(os.Stdout).Encode(&report)
+			}
+			if err != nil {
+				return fmt.Errorf("Failed to run: %s", err.Error())
+			}
+
+			return nil
+		},
+	}
+}